@@ -1,22 +1,88 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"os"
+	"time"
 
 	"github.com/aphilas/pgmcp/mcp"
 	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
 )
 
 func main() {
-	transport := jsonrpc.NewStdioServer(os.Stdin, os.Stdout, os.Stderr)
-	log.Printf("starting stdio jsonrpc server\n")
+	dsn := flag.String("dsn", os.Getenv("DATABASE_URL"), "Postgres connection string (default $DATABASE_URL)")
+	httpAddr := flag.String("http", "", "serve the Streamable HTTP transport on this address instead of stdio, e.g. localhost:8080")
+	httpIdleTimeout := flag.Duration("http-idle-timeout", jsonrpc.DefaultIdleTimeout, "close HTTP sessions without requests or open stream for this long (0 disables)")
+	keepAlive := flag.Duration("keepalive", 0, "ping clients at this interval and disconnect those that do not answer, e.g. 30s (0 disables)")
+	pageSize := flag.Int("page-size", mcp.DefaultPageSize, "number of items list requests return per page")
+	installTriggers := flag.Bool("install-triggers", false, "install triggers notifying pgmcp of changes on the tables of subscribed resources")
+	schemaPoll := flag.Duration("schema-poll", time.Minute, "reload the database catalog at this interval to detect schema changes (0 disables)")
+	installEventTrigger := flag.Bool("install-event-trigger", false, "install an event trigger notifying pgmcp of schema changes (requires superuser)")
+	dev := flag.Bool("dev", false, "fail tool calls whose results do not match their output schema instead of logging them")
+	flag.Parse()
 
-	server, err := mcp.NewServer(transport)
+	opts := mcp.Options{
+		KeepAlive:           *keepAlive,
+		PageSize:            *pageSize,
+		InstallTriggers:     *installTriggers,
+		SchemaPollInterval:  *schemaPoll,
+		InstallEventTrigger: *installEventTrigger,
+		Dev:                 *dev,
+	}
+	if *dsn != "" {
+		db, err := postgres.Connect(context.Background(), *dsn)
+		if err != nil {
+			log.Fatalf("connecting to database: %v\n", err)
+		}
+		superuser, err := postgres.IsSuperuser(context.Background(), db)
+		if err != nil {
+			log.Printf("%v\n", err)
+		} else if superuser {
+			log.Printf("warning: connected as a superuser, which read-only transactions don't confine; connect as a role granted pg_read_all_data instead\n")
+		}
+		opts.DB = db
+	} else {
+		log.Printf("no database configured, database tools are disabled\n")
+	}
+
+	var transport mcp.Transport
+	if *httpAddr != "" {
+		httpServer := jsonrpc.NewHTTPServer(*httpAddr, os.Stderr)
+		httpServer.IdleTimeout = *httpIdleTimeout
+		if *httpIdleTimeout == 0 {
+			httpServer.IdleTimeout = -1
+		}
+		transport = httpServer
+		log.Printf("starting http jsonrpc server\n")
+	} else {
+		transport = jsonrpc.NewStdioServer(os.Stdin, os.Stdout, os.Stderr)
+		log.Printf("starting stdio jsonrpc server\n")
+	}
+
+	server, err := mcp.NewServer(transport, opts)
 	if err != nil {
 		log.Fatalf("creating server: %v\n", err)
 	}
 
 	log.Printf("starting server with protocol version %s\n", server.ProtocolVersion)
-	server.Transport.Serve()
+	err = server.Transport.Serve()
+
+	// Stop listening for changes and close the database connections before
+	// exiting, also when the session was closed because the client stopped
+	// responding.
+	server.Close()
+	if opts.DB != nil {
+		opts.DB.Close()
+	}
+	if errors.Is(err, jsonrpc.ErrSessionClosed) {
+		log.Printf("session closed\n")
+		return
+	}
+	if err != nil {
+		log.Fatalf("serving: %v\n", err)
+	}
 }
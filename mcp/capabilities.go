@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Object is a JSON object without defined properties. Capabilities use it to
+// signal support for a feature by their presence.
+type Object struct{}
+
+// ClientCapabilities defines capabilities a client may support. A nil field
+// means the client does not support the feature.
+type ClientCapabilities struct {
+	// Experimental holds non-standard capabilities that the client supports.
+	Experimental map[string]json.RawMessage `json:"experimental,omitempty"`
+	Roots        *RootsCapability           `json:"roots,omitempty"`
+	Sampling     *SamplingCapability        `json:"sampling,omitempty"`
+	Elicitation  *ElicitationCapability     `json:"elicitation,omitempty"`
+	Tasks        *ClientTasksCapability     `json:"tasks,omitempty"`
+}
+
+// RootsCapability indicates that the client supports listing roots.
+type RootsCapability struct {
+	// ListChanged is set if the client sends notifications when the roots
+	// list changes.
+	ListChanged *bool `json:"listChanged,omitempty"`
+}
+
+// SamplingCapability indicates that the client supports sampling from an LLM.
+type SamplingCapability struct {
+	// Context is present if the client supports includeContext values other
+	// than "none".
+	Context *Object `json:"context,omitempty"`
+	// Tools is present if the client supports tool use during sampling.
+	Tools *Object `json:"tools,omitempty"`
+}
+
+// ElicitationCapability indicates that the client supports elicitation. An
+// empty object means form mode only.
+type ElicitationCapability struct {
+	Form *Object `json:"form,omitempty"`
+	URL  *Object `json:"url,omitempty"`
+}
+
+// ClientTasksCapability indicates that the client supports task-augmented
+// requests.
+type ClientTasksCapability struct {
+	List     *Object                        `json:"list,omitempty"`
+	Cancel   *Object                        `json:"cancel,omitempty"`
+	Requests *ClientTasksRequestsCapability `json:"requests,omitempty"`
+}
+
+// ClientTasksRequestsCapability lists the requests the client can run as
+// tasks.
+type ClientTasksRequestsCapability struct {
+	Sampling *struct {
+		CreateMessage *Object `json:"createMessage,omitempty"`
+	} `json:"sampling,omitempty"`
+	Elicitation *struct {
+		Create *Object `json:"create,omitempty"`
+	} `json:"elicitation,omitempty"`
+}
+
+// CanListRoots reports whether the server may send roots/list requests.
+func (c ClientCapabilities) CanListRoots() bool {
+	return c.Roots != nil
+}
+
+// CanSample reports whether the server may send sampling/createMessage
+// requests.
+func (c ClientCapabilities) CanSample() bool {
+	return c.Sampling != nil
+}
+
+// CanElicitForm reports whether the server may send form mode
+// elicitation/create requests, e.g. to ask the user for confirmation.
+func (c ClientCapabilities) CanElicitForm() bool {
+	if c.Elicitation == nil {
+		return false
+	}
+
+	// For backwards compatibility an empty elicitation capability means form
+	// mode.
+	return c.Elicitation.Form != nil || c.Elicitation.URL == nil
+}
+
+// CanElicitURL reports whether the server may send URL mode
+// elicitation/create requests.
+func (c ClientCapabilities) CanElicitURL() bool {
+	return c.Elicitation != nil && c.Elicitation.URL != nil
+}
+
+// ClientCapabilitiesFromContext returns the capabilities the client declared
+// when it initialized the session the request in ctx belongs to. Tools use it
+// to check whether the client supports a feature before relying on it. A
+// context without a session reports no support for anything.
+func ClientCapabilitiesFromContext(ctx context.Context) ClientCapabilities {
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return ClientCapabilities{}
+	}
+
+	return sess.Capabilities()
+}
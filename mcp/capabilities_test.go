@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestClientCapabilities(t *testing.T) {
+	tests := []struct {
+		name           string
+		capabilities   string
+		wantRoots      bool
+		wantSampling   bool
+		wantElicitForm bool
+		wantElicitURL  bool
+	}{
+		{"none", `{}`, false, false, false, false},
+		{"roots", `{"roots":{"listChanged":true}}`, true, false, false, false},
+		{"sampling", `{"sampling":{}}`, false, true, false, false},
+		{"empty elicitation", `{"elicitation":{}}`, false, false, true, false},
+		{"form elicitation", `{"elicitation":{"form":{}}}`, false, false, true, false},
+		{"url elicitation", `{"elicitation":{"url":{}}}`, false, false, false, true},
+		{"both elicitation", `{"elicitation":{"form":{},"url":{}}}`, false, false, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c ClientCapabilities
+			if err := json.Unmarshal([]byte(tt.capabilities), &c); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+
+			if got := c.CanListRoots(); got != tt.wantRoots {
+				t.Errorf("CanListRoots() = %v, want %v", got, tt.wantRoots)
+			}
+			if got := c.CanSample(); got != tt.wantSampling {
+				t.Errorf("CanSample() = %v, want %v", got, tt.wantSampling)
+			}
+			if got := c.CanElicitForm(); got != tt.wantElicitForm {
+				t.Errorf("CanElicitForm() = %v, want %v", got, tt.wantElicitForm)
+			}
+			if got := c.CanElicitURL(); got != tt.wantElicitURL {
+				t.Errorf("CanElicitURL() = %v, want %v", got, tt.wantElicitURL)
+			}
+		})
+	}
+}
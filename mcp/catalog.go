@@ -0,0 +1,138 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// catalogTimeout bounds loading the catalog of the database.
+const catalogTimeout = 10 * time.Second
+
+// catalogWatcher caches the catalog of the database, from which the table
+// resources are made.
+type catalogWatcher struct {
+	db *pgxpool.Pool
+
+	// mu serializes applying catalogs.
+	mu      sync.Mutex
+	catalog *postgres.Catalog
+
+	// refresh requests a refresh of the catalog. Requests made while one is
+	// pending are merged into it.
+	refresh chan struct{}
+}
+
+func newCatalogWatcher(db *pgxpool.Pool) *catalogWatcher {
+	return &catalogWatcher{
+		db:      db,
+		catalog: &postgres.Catalog{},
+		refresh: make(chan struct{}, 1),
+	}
+}
+
+// current returns the cached catalog.
+func (w *catalogWatcher) current() *postgres.Catalog {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.catalog
+}
+
+// loadCatalog loads the catalog of the database, registers a TableResource for
+// each table and the templates reading rows of the tables.
+func (s *Server) loadCatalog(ctx context.Context) error {
+	catalog, err := postgres.LoadCatalog(ctx, s.catalog.db)
+	if err != nil {
+		return fmt.Errorf("loading catalog: %w", err)
+	}
+	s.applyCatalog(catalog)
+
+	err = s.AddResourceTemplate(NewTableSampleTemplate(s.catalog.db, catalog.Database))
+	if err != nil {
+		return err
+	}
+	return s.AddResourceTemplate(NewTableRowTemplate(s.catalog.db, catalog.Database))
+}
+
+// applyCatalog replaces the cached catalog. The resources of dropped tables
+// are removed and resources are added for new tables, after which clients are
+// told that the list of resources changed. Clients subscribed to the resource
+// of an altered table are told that it changed.
+func (s *Server) applyCatalog(catalog *postgres.Catalog) {
+	s.catalog.mu.Lock()
+	defer s.catalog.mu.Unlock()
+
+	old := s.catalog.catalog
+	s.catalog.catalog = catalog
+
+	listChanged := false
+	for _, table := range old.Tables {
+		if _, ok := catalog.Versions[table]; !ok {
+			s.resources.remove(TableURI(old.Database, table))
+			listChanged = true
+		}
+	}
+
+	for _, table := range catalog.Tables {
+		uri := TableURI(catalog.Database, table)
+		version, ok := old.Versions[table]
+		switch {
+		case !ok:
+			s.resources.add(uri, NewTableResource(s.catalog.db, catalog.Database, table))
+			listChanged = true
+		case version != catalog.Versions[table]:
+			s.ResourceUpdated(uri)
+		}
+	}
+
+	if listChanged {
+		s.notifyAll("notifications/resources/list_changed", nil)
+	}
+}
+
+// requestCatalogRefresh asks watchCatalog to refresh the catalog. It does not
+// block.
+func (s *Server) requestCatalogRefresh() {
+	select {
+	case s.catalog.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// watchCatalog refreshes the catalog every interval, if interval is not zero,
+// and when requested, until the server is closed.
+func (s *Server) watchCatalog(interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-s.catalog.refresh:
+		case <-s.ctx.Done():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, catalogTimeout)
+		catalog, err := postgres.LoadCatalog(ctx, s.catalog.db)
+		cancel()
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Refreshing catalog failed: %v", err)
+			continue
+		}
+		s.applyCatalog(catalog)
+	}
+}
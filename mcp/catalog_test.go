@@ -0,0 +1,82 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aphilas/pgmcp/pkg/postgres"
+)
+
+func TestApplyCatalog(t *testing.T) {
+	c := newTestClient(t)
+	c.server.catalog = newCatalogWatcher(nil)
+
+	actor := postgres.TableName{Schema: "public", Name: "actor"}
+	film := postgres.TableName{Schema: "public", Name: "film"}
+	rental := postgres.TableName{Schema: "public", Name: "rental"}
+
+	c.server.applyCatalog(&postgres.Catalog{
+		Database: "db",
+		Tables:   []postgres.TableName{actor, film},
+		Versions: map[postgres.TableName]string{actor: "1", film: "1"},
+	})
+	if n := c.notification(); n.Method != "notifications/resources/list_changed" {
+		t.Errorf("Method = %q, want notifications/resources/list_changed", n.Method)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":{"uri":"` + TableURI("db", film) + `"}}`)
+	c.result("1", &struct{}{})
+
+	// actor is dropped, film altered and rental created.
+	c.server.applyCatalog(&postgres.Catalog{
+		Database: "db",
+		Tables:   []postgres.TableName{film, rental},
+		Versions: map[postgres.TableName]string{film: "2", rental: "1"},
+	})
+
+	got := map[string]bool{}
+	for len(got) < 2 {
+		n := c.notification()
+		got[n.Method] = true
+	}
+	if !got["notifications/resources/list_changed"] || !got["notifications/resources/updated"] {
+		t.Errorf("notifications = %v, want list_changed and updated", got)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"resources/list"}`)
+	var result ListResourcesResult
+	c.result("2", &result)
+	var uris []string
+	for _, resource := range result.Resources {
+		uris = append(uris, resource.URI)
+	}
+	want := []string{TableURI("db", film), TableURI("db", rental)}
+	if len(uris) != len(want) || uris[0] != want[0] || uris[1] != want[1] {
+		t.Errorf("resources = %v, want %v", uris, want)
+	}
+}
+
+func TestCloseStopsWatchingCatalog(t *testing.T) {
+	c := newTestClient(t)
+	c.server.catalog = newCatalogWatcher(nil)
+	c.server.goBackground(func() { c.server.watchCatalog(time.Hour) })
+
+	closed := make(chan struct{})
+	go func() {
+		c.server.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	ran := false
+	c.server.goBackground(func() { ran = true })
+	c.server.background.Wait()
+	if ran {
+		t.Error("goBackground ran after Close")
+	}
+}
@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"fmt"
+)
+
+// Role is the sender or recipient of messages and data in a conversation.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Annotations tell the client how to use or display an object.
+type Annotations struct {
+	// Audience is who the object is intended for.
+	Audience []Role `json:"audience,omitempty"`
+	// Priority is how important the object is, from 0 (entirely optional) to
+	// 1 (effectively required).
+	Priority *float64 `json:"priority,omitempty"`
+	// LastModified is an ISO 8601 timestamp of the last modification.
+	LastModified *string `json:"lastModified,omitempty"`
+}
+
+// Content is a content block of a tool result: TextContent, ImageContent,
+// AudioContent, ResourceLink or EmbeddedResource.
+type Content interface {
+	// contentType returns the value of the type field.
+	contentType() string
+}
+
+// TextContent represents text content returned by a tool call.
+type TextContent struct {
+	Type        string       `json:"type"` // always "text"
+	Text        string       `json:"text"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+}
+
+// ImageContent is an image. Data is sent base64 encoded.
+type ImageContent struct {
+	Type        string       `json:"type"` // always "image"
+	Data        []byte       `json:"data"`
+	MimeType    string       `json:"mimeType"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+}
+
+// AudioContent is audio. Data is sent base64 encoded.
+type AudioContent struct {
+	Type        string       `json:"type"` // always "audio"
+	Data        []byte       `json:"data"`
+	MimeType    string       `json:"mimeType"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+}
+
+// Resource describes a resource the client can read. Omitted: icons, _meta.
+type Resource struct {
+	URI         string       `json:"uri"`
+	Name        string       `json:"name"`
+	Title       *string      `json:"title,omitempty"`
+	Description *string      `json:"description,omitempty"`
+	MimeType    *string      `json:"mimeType,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	// Size is the size of the raw content in bytes, if known.
+	Size *int64 `json:"size,omitempty"`
+}
+
+// ResourceLink points to a resource the client can read. Linked resources
+// don't necessarily appear in resources/list.
+type ResourceLink struct {
+	Type string `json:"type"` // always "resource_link"
+	Resource
+}
+
+// ResourceContents are the contents of a resource. Exactly one of Text and
+// Blob is set; Blob is sent base64 encoded.
+type ResourceContents struct {
+	URI      string  `json:"uri"`
+	MimeType *string `json:"mimeType,omitempty"`
+	Text     *string `json:"text,omitempty"`
+	Blob     []byte  `json:"blob,omitempty"`
+}
+
+// EmbeddedResource is the contents of a resource embedded in a result.
+type EmbeddedResource struct {
+	Type        string           `json:"type"` // always "resource"
+	Resource    ResourceContents `json:"resource"`
+	Annotations *Annotations     `json:"annotations,omitempty"`
+}
+
+func (TextContent) contentType() string      { return "text" }
+func (ImageContent) contentType() string     { return "image" }
+func (AudioContent) contentType() string     { return "audio" }
+func (ResourceLink) contentType() string     { return "resource_link" }
+func (EmbeddedResource) contentType() string { return "resource" }
+
+func NewTextContent(text string) TextContent {
+	return TextContent{Type: "text", Text: text}
+}
+
+func NewImageContent(data []byte, mimeType string) ImageContent {
+	return ImageContent{Type: "image", Data: data, MimeType: mimeType}
+}
+
+func NewAudioContent(data []byte, mimeType string) AudioContent {
+	return AudioContent{Type: "audio", Data: data, MimeType: mimeType}
+}
+
+func NewResourceLink(resource Resource) ResourceLink {
+	return ResourceLink{Type: "resource_link", Resource: resource}
+}
+
+func NewEmbeddedResource(contents ResourceContents) EmbeddedResource {
+	return EmbeddedResource{Type: "resource", Resource: contents}
+}
+
+// linksAsText replaces the resource links in contents with text naming the
+// linked resource.
+func linksAsText(contents []Content) []Content {
+	replaced := make([]Content, len(contents))
+	for i, content := range contents {
+		link, ok := content.(ResourceLink)
+		if !ok {
+			replaced[i] = content
+			continue
+		}
+		replaced[i] = NewTextContent(fmt.Sprintf("Resource %s: %s", link.Name, link.URI))
+	}
+	return replaced
+}
@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/types"
+)
+
+// The server never decodes content; only the tests read results back.
+
+// unmarshalContent decodes a content block according to its type field.
+func unmarshalContent(data []byte) (Content, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	var content Content
+	switch header.Type {
+	case "text":
+		var c TextContent
+		err = json.Unmarshal(data, &c)
+		content = c
+	case "image":
+		var c ImageContent
+		err = json.Unmarshal(data, &c)
+		content = c
+	case "audio":
+		var c AudioContent
+		err = json.Unmarshal(data, &c)
+		content = c
+	case "resource_link":
+		var c ResourceLink
+		err = json.Unmarshal(data, &c)
+		content = c
+	case "resource":
+		var c EmbeddedResource
+		err = json.Unmarshal(data, &c)
+		content = c
+	default:
+		return nil, fmt.Errorf("unknown content type %q", header.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// unmarshalContents decodes a list of content blocks.
+func unmarshalContents(data []json.RawMessage) ([]Content, error) {
+	contents := make([]Content, len(data))
+	for i, raw := range data {
+		content, err := unmarshalContent(raw)
+		if err != nil {
+			return nil, err
+		}
+		contents[i] = content
+	}
+	return contents, nil
+}
+
+func (r *CallToolResult) UnmarshalJSON(data []byte) error {
+	type callToolResult CallToolResult
+	var raw struct {
+		callToolResult
+		Content []json.RawMessage `json:"content"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	*r = CallToolResult(raw.callToolResult)
+	r.Content, err = unmarshalContents(raw.Content)
+	return err
+}
+
+func (m *PromptMessage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role    Role            `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	m.Role = raw.Role
+	m.Content, err = unmarshalContent(raw.Content)
+	return err
+}
+
+func TestContentRoundTrip(t *testing.T) {
+	annotated := NewImageContent([]byte{0x89, 'P', 'N', 'G'}, "image/png")
+	annotated.Annotations = &Annotations{
+		Audience: []Role{RoleUser},
+		Priority: types.Ptr(0.5),
+	}
+
+	tests := []struct {
+		name     string
+		content  Content
+		wantType string
+	}{
+		{"text", NewTextContent("hello"), "text"},
+		{"image", annotated, "image"},
+		{"audio", NewAudioContent([]byte("RIFF"), "audio/wav"), "audio"},
+		{"resource_link", NewResourceLink(Resource{
+			URI:      "postgres://db/schemas/public/tables/users",
+			Name:     "users",
+			MimeType: types.Ptr("application/json"),
+		}), "resource_link"},
+		{"resource", NewEmbeddedResource(ResourceContents{
+			URI:  "postgres://db/schemas/public/tables/users",
+			Text: types.Ptr("users table"),
+		}), "resource"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CallToolResult{Content: []Content{tt.content}}
+			data, err := json.Marshal(result)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if !strings.Contains(string(data), `"type":"`+tt.wantType+`"`) {
+				t.Errorf("Marshal = %s, want type %q", data, tt.wantType)
+			}
+
+			var got CallToolResult
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got.Content, result.Content) {
+				t.Errorf("Content = %+v, want %+v", got.Content, result.Content)
+			}
+		})
+	}
+}
+
+func TestUnmarshalUnknownContent(t *testing.T) {
+	var result CallToolResult
+	err := json.Unmarshal([]byte(`{"content":[{"type":"video","data":""}]}`), &result)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// linkTool returns a resource link.
+type linkTool struct{}
+
+func (linkTool) Definition() Tool {
+	return Tool{Name: "link"}
+}
+
+func (linkTool) Execute(ctx context.Context, params json.RawMessage) (*CallToolResult, *jsonrpc.Error) {
+	return &CallToolResult{
+		Content: []Content{NewResourceLink(Resource{URI: "pgmcp://exports/1.csv", Name: "export"})},
+	}, nil
+}
+
+func TestResourceLinkGatedOnVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		wantType string
+	}{
+		{"2025-06-18", "resource_link"},
+		{"2025-03-26", "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			resps := serveWith(t, Options{}, func(s *Server) { s.AddTool(linkTool{}) },
+				strings.Replace(initializeRequest, ProtocolVersion, tt.version, 1),
+				initializedNotification,
+				`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"link"}}`,
+			)
+
+			resp := resps["1"]
+			if resp.Error != nil {
+				t.Fatalf("tools/call: unexpected error: %v", resp.Error)
+			}
+			var result CallToolResult
+			if err := json.Unmarshal(resp.Result, &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if len(result.Content) != 1 || result.Content[0].contentType() != tt.wantType {
+				t.Errorf("Content = %+v, want one %s block", result.Content, tt.wantType)
+			}
+		})
+	}
+}
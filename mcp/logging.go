@@ -0,0 +1,132 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/types"
+)
+
+// LoggingLevel is the severity of a log message. The levels map to the syslog
+// severities of RFC 5424.
+type LoggingLevel string
+
+const (
+	LevelDebug     LoggingLevel = "debug"
+	LevelInfo      LoggingLevel = "info"
+	LevelNotice    LoggingLevel = "notice"
+	LevelWarning   LoggingLevel = "warning"
+	LevelError     LoggingLevel = "error"
+	LevelCritical  LoggingLevel = "critical"
+	LevelAlert     LoggingLevel = "alert"
+	LevelEmergency LoggingLevel = "emergency"
+)
+
+// loggingLevels lists the levels by increasing severity.
+var loggingLevels = []LoggingLevel{
+	LevelDebug,
+	LevelInfo,
+	LevelNotice,
+	LevelWarning,
+	LevelError,
+	LevelCritical,
+	LevelAlert,
+	LevelEmergency,
+}
+
+// severity returns the rank of the level, or -1 for an unknown level.
+func (l LoggingLevel) severity() int {
+	return slices.Index(loggingLevels, l)
+}
+
+// LoggingCapability indicates that the server sends log messages to the
+// client.
+type LoggingCapability struct{}
+
+// SetLevelRequestParams contains parameters for a logging/setLevel request.
+type SetLevelRequestParams struct {
+	// Level is the minimum level of the messages the client wants to receive.
+	Level LoggingLevel `json:"level"`
+}
+
+// LoggingMessageNotificationParams contains parameters for a
+// notifications/message notification.
+type LoggingMessageNotificationParams struct {
+	Level LoggingLevel `json:"level"`
+	// Logger is the name of the component that logged the message.
+	Logger *string `json:"logger,omitempty"`
+	// Data is any JSON serializable value.
+	Data any `json:"data"`
+}
+
+// SetLevel is called when the client sends the "logging/setLevel" request.
+// Messages below the level are no longer sent to the client.
+func (s *Server) SetLevel(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	var params SetLevelRequestParams
+	err := json.Unmarshal(p, &params)
+	if err != nil || params.Level.severity() < 0 {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Invalid params",
+		}
+	}
+
+	sess := s.session(ctx)
+	sess.mu.Lock()
+	sess.logLevel = params.Level
+	sess.mu.Unlock()
+
+	return jsonrpc.EmptyResult(), nil
+}
+
+// Log writes a message to the server log and sends it to the client of the
+// session ctx belongs to, if the client asked for messages at level. logger
+// names the component logging the message and data is any JSON serializable
+// value, typically a string or an object with a "message" field.
+func Log(ctx context.Context, level LoggingLevel, logger string, data any) {
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		log.Printf("%s: %s: %v", level, logger, data)
+		return
+	}
+
+	sess.log(ctx, level, logger, data)
+}
+
+// log writes a message to the server log and sends it to the client if the
+// client asked for messages at level. The notification goes to the transport
+// session of ctx when there is one, so that messages logged while handling a
+// request are sent on that request's stream.
+func (sess *session) log(ctx context.Context, level LoggingLevel, logger string, data any) {
+	log.Printf("%s: %s: %v", level, logger, data)
+
+	sess.mu.Lock()
+	minLevel := sess.logLevel
+	sess.mu.Unlock()
+
+	// Nothing is sent until the client selects a level.
+	if minLevel == "" || level.severity() < minLevel.severity() {
+		return
+	}
+
+	transportSession := jsonrpc.SessionFromContext(ctx)
+	if transportSession == nil {
+		transportSession = sess.Session
+	}
+	if transportSession == nil {
+		return
+	}
+
+	params := types.NewRawJSON(LoggingMessageNotificationParams{
+		Level:  level,
+		Logger: &logger,
+		Data:   data,
+	})
+	err := transportSession.Notify(ctx, "notifications/message", params)
+	if err != nil {
+		log.Printf("Failed to send log message: %v", err)
+	}
+}
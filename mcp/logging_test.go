@@ -0,0 +1,90 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+// failingTool always fails with a protocol error.
+type failingTool struct{}
+
+func (failingTool) Definition() Tool {
+	return Tool{Name: "fail"}
+}
+
+func (failingTool) Execute(ctx context.Context, params json.RawMessage) (*CallToolResult, *jsonrpc.Error) {
+	return nil, &jsonrpc.Error{Code: jsonrpc.CodeInternalError, Message: "boom"}
+}
+
+func TestLogFilteredByLevel(t *testing.T) {
+	lines := []string{
+		initializeRequest,
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fail"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"logging/setLevel","params":{"level":"warning"}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"fail"}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"logging/setLevel","params":{"level":"critical"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"fail"}}`,
+	}
+	out := &bytes.Buffer{}
+	in, start := clientInput(lines...)
+	transport := jsonrpc.NewStdioServer(in, out, io.Discard)
+	transport.MaxConcurrent = 1
+
+	s, err := NewServer(transport, Options{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	s.AddTool(failingTool{})
+	start(s)
+	transport.Serve()
+
+	var messages []LoggingMessageNotificationParams
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var msg jsonrpc.Request
+		if err := dec.Decode(&msg); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if msg.Method != "notifications/message" {
+			continue
+		}
+
+		var params LoggingMessageNotificationParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		messages = append(messages, params)
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("got %d log messages, want 1: %+v", len(messages), messages)
+	}
+	if messages[0].Level != LevelError {
+		t.Errorf("Level = %q, want %q", messages[0].Level, LevelError)
+	}
+	if messages[0].Logger == nil || *messages[0].Logger != "fail" {
+		t.Errorf("Logger = %v, want fail", messages[0].Logger)
+	}
+}
+
+func TestSetLevelInvalid(t *testing.T) {
+	resps := serve(t,
+		initializeRequest,
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"logging/setLevel","params":{"level":"verbose"}}`,
+	)
+
+	resp := resps["1"]
+	if resp.Error == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if resp.Error.Code != jsonrpc.CodeInvalidParams {
+		t.Errorf("Error.Code = %d, want %d", resp.Error.Code, jsonrpc.CodeInvalidParams)
+	}
+}
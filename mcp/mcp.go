@@ -1,58 +1,271 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
 	"github.com/aphilas/pgmcp/pkg/types"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ProtocolVersion is the latest protocol version the server supports.
 const ProtocolVersion = "2025-11-25"
 
+// SupportedProtocolVersions lists the protocol versions the server can
+// negotiate, newest first.
+var SupportedProtocolVersions = []string{ProtocolVersion, "2025-06-18", "2025-03-26"}
+
+// Protocol versions that introduced features the server gates on the
+// negotiated version. Versions are dates, so they compare as strings.
+const (
+	// versionStructuredContent introduced titles, Tool.outputSchema,
+	// CallToolResult.structuredContent and resource links.
+	versionStructuredContent = "2025-06-18"
+	// versionTasks introduced tasks and Tool.execution.
+	versionTasks = "2025-11-25"
+)
+
 type Server struct {
 	ProtocolVersion string
 	ServerInfo      Implementation
 	Transport       Transport
 
-	Tools map[string]Tooler
+	// tools holds the tools the server offers, in registration order.
+	tools *registry[*registeredTool]
+
+	// resources holds the resources the server offers, keyed by URI, in
+	// registration order.
+	resources *registry[Resourcer]
+
+	// templates holds the resource templates the server offers, keyed by URI
+	// template, in registration order.
+	templates *registry[*registeredTemplate]
+
+	// prompts holds the prompts the server offers, in registration order.
+	prompts *registry[Prompter]
+
+	// changes listens for changes of the tables of subscribed resources and
+	// of the schema. It is nil if the server has no database.
+	changes *changeListener
+
+	// catalog caches the catalog of the database. It is nil if the server has
+	// no database.
+	catalog *catalogWatcher
+
+	// tasks holds the tool calls running as tasks.
+	tasks *taskStore
+
+	// pageSize is the number of items list methods return per page.
+	pageSize int
+
+	// dev enables strict checks of the server's own behavior.
+	dev bool
+
+	// keepAlive is the interval between pings to each client. Zero disables
+	// pinging.
+	keepAlive time.Duration
+
+	// ctx is cancelled by Close to stop the background work of the server,
+	// e.g. listening for table changes. background tracks that work.
+	ctx          context.Context
+	cancel       context.CancelFunc
+	backgroundMu sync.Mutex
+	background   sync.WaitGroup
+
+	sessionsMu sync.Mutex
+	// sessions holds the state of connected clients, keyed by transport
+	// session ID.
+	sessions map[string]*session
 }
 
 type Transport interface {
 	RegisterMethod(name string, method jsonrpc.Method)
-	Serve()
+	RegisterNotification(name string, handler jsonrpc.NotificationHandler)
+	Serve() error
+}
+
+// Options configures the optional features of a Server.
+type Options struct {
+	// DB is the Postgres database the database tools and prompts run against
+	// and whose tables are offered as resources. The database tools,
+	// resources and prompts are not registered if DB is nil.
+	DB *pgxpool.Pool
+
+	// KeepAlive is the interval at which the server pings each client. A
+	// client that does not answer a ping within the interval is disconnected,
+	// which cancels its running queries. An HTTP client without an open GET
+	// stream can't be pinged; it is disconnected if it sends nothing for the
+	// interval. Zero disables pinging.
+	KeepAlive time.Duration
+
+	// PageSize is the number of items list methods such as tools/list return
+	// per page. Zero means DefaultPageSize.
+	PageSize int
+
+	// InstallTriggers makes the server install triggers notifying it of
+	// changes on the tables of resources clients subscribe to. Otherwise
+	// clients are only told about changes the application notifies itself;
+	// see postgres.ChangeChannel.
+	InstallTriggers bool
+
+	// SchemaPollInterval is the interval at which the server reloads the
+	// catalog of the database to detect schema changes. Zero disables polling.
+	SchemaPollInterval time.Duration
+
+	// InstallEventTrigger makes the server install an event trigger notifying
+	// it of schema changes, which requires superuser privileges. If the
+	// trigger can't be installed, only polling detects schema changes.
+	InstallEventTrigger bool
+
+	// Dev makes the server fail tool calls whose structured result does not
+	// match the tool's output schema with an internal error. Otherwise the
+	// mismatch is only logged.
+	Dev bool
 }
 
-func NewServer(transport Transport) (*Server, error) {
+func NewServer(transport Transport, opts Options) (*Server, error) {
 	calculatorTool, err := NewCalculator()
 	if err != nil {
 		return nil, fmt.Errorf("creating calculator tool: %w", err)
 	}
 
-	s := Server{
+	s := &Server{
 		ServerInfo: Implementation{
 			Name:    "pgmcp",
 			Version: "0.0.1",
 		},
 		ProtocolVersion: ProtocolVersion,
-		Tools: map[string]Tooler{
-			"calculator": calculatorTool,
-		},
-		Transport: transport,
+		tools:           newRegistry[*registeredTool](),
+		resources:       newRegistry[Resourcer](),
+		templates:       newRegistry[*registeredTemplate](),
+		prompts:         newRegistry[Prompter](),
+		Transport:       transport,
+		tasks:           newTaskStore(),
+		pageSize:        opts.PageSize,
+		dev:             opts.Dev,
+		keepAlive:       opts.KeepAlive,
+		sessions:        make(map[string]*session),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	// No client is connected yet, so adding tools notifies nobody.
+	err = s.AddTool(calculatorTool)
+	if err != nil {
+		return nil, err
 	}
 
+	if opts.DB != nil {
+		queryTool, err := NewQuery(opts.DB)
+		if err != nil {
+			return nil, fmt.Errorf("creating query tool: %w", err)
+		}
+		err = s.AddTool(queryTool)
+		if err != nil {
+			return nil, err
+		}
+
+		s.changes = newChangeListener(opts.DB, opts.InstallTriggers)
+		s.catalog = newCatalogWatcher(opts.DB)
+
+		ctx, cancel := context.WithTimeout(context.Background(), catalogTimeout)
+		defer cancel()
+		err = s.loadCatalog(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		catalog := s.catalog.current
+		s.AddPrompt(NewExplainQueryPrompt(opts.DB, catalog))
+		s.AddPrompt(NewOptimizeQueryPrompt(opts.DB, catalog))
+		s.AddPrompt(NewDescribeTablePrompt(opts.DB, catalog))
+		s.AddPrompt(NewWriteQueryPrompt(opts.DB, catalog))
+
+		eventTrigger := false
+		if opts.InstallEventTrigger {
+			err = postgres.InstallDDLTrigger(ctx, opts.DB)
+			if err != nil {
+				log.Printf("Detecting schema changes by polling only: %v", err)
+			} else {
+				eventTrigger = true
+				s.startListening()
+			}
+		}
+		if eventTrigger || opts.SchemaPollInterval > 0 {
+			s.goBackground(func() { s.watchCatalog(opts.SchemaPollInterval) })
+		}
+	}
+
+	// initialize and ping are the only requests allowed before the session
+	// is initialized. Every other method is rejected until then.
+	s.Transport.RegisterMethod("initialize", s.Initialize)
+	s.Transport.RegisterMethod("ping", s.Ping)
+
 	methods := map[string]jsonrpc.Method{
-		"initialize":                s.Initialize,
-		"notifications/initialized": s.NotificationsInitialized,
-		"tools/list":                s.ListTools,
-		"tools/call":                s.CallTool,
+		"tools/list":               s.ListTools,
+		"tools/call":               s.CallTool,
+		"resources/list":           s.ListResources,
+		"resources/read":           s.ReadResource,
+		"resources/templates/list": s.ListResourceTemplates,
+		"resources/subscribe":      s.Subscribe,
+		"resources/unsubscribe":    s.Unsubscribe,
+		"prompts/list":             s.ListPrompts,
+		"prompts/get":              s.GetPrompt,
+		"logging/setLevel":         s.SetLevel,
+		"tasks/get":                s.GetTask,
+		"tasks/list":               s.ListTasks,
+		"tasks/result":             s.TaskResult,
+		"tasks/cancel":             s.CancelTask,
 	}
 
 	for name, method := range methods {
-		s.Transport.RegisterMethod(name, method)
+		s.Transport.RegisterMethod(name, s.requireInitialized(method))
+	}
+
+	notifications := map[string]jsonrpc.NotificationHandler{
+		"notifications/initialized": s.NotificationsInitialized,
+		"notifications/cancelled":   s.NotificationsCancelled,
 	}
 
-	return &s, nil
+	for name, handler := range notifications {
+		s.Transport.RegisterNotification(name, handler)
+	}
+
+	return s, nil
+}
+
+// Close stops the background work of the server and waits for it to finish.
+// Call it once the transport stopped serving; the server must not be used
+// afterwards.
+func (s *Server) Close() {
+	s.backgroundMu.Lock()
+	s.cancel()
+	s.backgroundMu.Unlock()
+
+	s.background.Wait()
+}
+
+// goBackground runs f in a goroutine that Close waits for. f must return soon
+// after s.ctx is done. f is not run if the server is closed.
+func (s *Server) goBackground(f func()) {
+	s.backgroundMu.Lock()
+	defer s.backgroundMu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		f()
+	}()
 }
 
 // Implementation describes the MCP implementation. Omitted: icons.
@@ -65,9 +278,13 @@ type Implementation struct {
 }
 
 // ServerCapabilities defines capabilities a server may support. Omitted:
-// experimental, logging, completions, prompts, resources, tasks.
+// experimental, completions.
 type ServerCapabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Logging   *LoggingCapability     `json:"logging,omitempty"`
+	Tools     *ToolsCapability       `json:"tools,omitempty"`
+	Resources *ResourcesCapability   `json:"resources,omitempty"`
+	Prompts   *PromptsCapability     `json:"prompts,omitempty"`
+	Tasks     *ServerTasksCapability `json:"tasks,omitempty"`
 }
 
 // ToolsCapability indicates if the server offers tools to call.
@@ -76,10 +293,10 @@ type ToolsCapability struct {
 }
 
 // InitializeParams contains parameters for an initialize request.
-// Omitted: capabilities.
 type InitializeParams struct {
-	ProtocolVersion string         `json:"protocolVersion"`
-	ClientInfo      Implementation `json:"clientInfo"`
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+	ClientInfo      Implementation     `json:"clientInfo"`
 }
 
 // InitializeResult is the server's response to an initialize request
@@ -90,12 +307,14 @@ type InitializeResult struct {
 	Instructions    *string            `json:"instructions,omitempty"`
 }
 
-type UnsupportedProtocolErrorData struct {
-	Supported []string `json:"supported"`
-	Requested string   `json:"requested"`
-}
-
-func (s Server) Initialize(p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+// Initialize is called when the client sends the "initialize" request. It
+// negotiates the protocol version and records the client's capabilities and
+// info for the session. A session can only be initialized once.
+//
+// If the server supports the requested protocol version it responds with the
+// same version, otherwise with the latest version it supports. The client
+// disconnects if it does not support that version either.
+func (s *Server) Initialize(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
 	var params InitializeParams
 	err := json.Unmarshal(p, &params)
 	if err != nil {
@@ -105,29 +324,113 @@ func (s Server) Initialize(p json.RawMessage) (json.RawMessage, *jsonrpc.Error)
 		}
 	}
 
-	if params.ProtocolVersion != s.ProtocolVersion {
+	protocolVersion := s.ProtocolVersion
+	if slices.Contains(SupportedProtocolVersions, params.ProtocolVersion) {
+		protocolVersion = params.ProtocolVersion
+	}
+
+	sess := s.session(ctx)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.state != StateUninitialized {
 		return nil, &jsonrpc.Error{
-			Code:    jsonrpc.CodeInvalidParams,
-			Message: "Unsupported protocol version",
-			Data: jsonrpc.JSONRawMessage(UnsupportedProtocolErrorData{
-				Supported: []string{s.ProtocolVersion},
-				Requested: params.ProtocolVersion,
-			}),
+			Code:    jsonrpc.CodeInvalidRequest,
+			Message: "Already initialized",
 		}
 	}
 
-	return types.NewRawJSON(InitializeResult{
-		ProtocolVersion: s.ProtocolVersion,
-		Capabilities: ServerCapabilities{
-			Tools: &ToolsCapability{},
+	sess.state = StateInitializing
+	sess.protocolVersion = protocolVersion
+	sess.clientInfo = params.ClientInfo
+	sess.capabilities = params.Capabilities
+
+	capabilities := ServerCapabilities{
+		Logging: &LoggingCapability{},
+		Tools: &ToolsCapability{
+			ListChanged: types.Ptr(true),
+		},
+		Resources: &ResourcesCapability{
+			Subscribe:   types.Ptr(true),
+			ListChanged: types.Ptr(true),
+		},
+		Prompts: &PromptsCapability{
+			ListChanged: types.Ptr(true),
 		},
-		ServerInfo: s.ServerInfo,
+	}
+	if protocolVersion >= versionTasks {
+		capabilities.Tasks = &ServerTasksCapability{
+			List:   &Object{},
+			Cancel: &Object{},
+			Requests: &ServerTasksRequestsCapability{
+				Tools: &struct {
+					Call *Object `json:"call,omitempty"`
+				}{Call: &Object{}},
+			},
+		}
+	}
+
+	return types.NewRawJSON(InitializeResult{
+		ProtocolVersion: protocolVersion,
+		Capabilities:    capabilities,
+		ServerInfo:      s.ServerInfo,
 	}), nil
 }
 
+// Ping is called when the client sends the "ping" request. It is answered
+// with an empty result at any stage of the session. Pinging keeps an HTTP
+// session without a stream alive; see Options.KeepAlive.
+func (s *Server) Ping(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	s.session(ctx)
+	return jsonrpc.EmptyResult(), nil
+}
+
 // NotificationsInitialized is called when the client sends the
-// "notifications/initialized" notification.
-func (s Server) NotificationsInitialized(p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
-	// TODO: Handle initialized notification
-	return nil, nil
+// "notifications/initialized" notification. The session is ready for normal
+// operation.
+func (s *Server) NotificationsInitialized(ctx context.Context, p json.RawMessage) {
+	sess := s.session(ctx)
+	sess.mu.Lock()
+	state := sess.state
+	if state == StateInitializing {
+		sess.state = StateReady
+	}
+	clientInfo := sess.clientInfo
+	sess.mu.Unlock()
+
+	if state != StateInitializing {
+		sess.log(ctx, LevelWarning, "session", fmt.Sprintf("Ignoring initialized notification in state %s", state))
+		return
+	}
+
+	sess.log(ctx, LevelInfo, "session", fmt.Sprintf("Session initialized by %s %s", clientInfo.Name, clientInfo.Version))
+}
+
+// CancelledNotificationParams contains parameters for a
+// notifications/cancelled notification.
+type CancelledNotificationParams struct {
+	// RequestID is the ID of the request to cancel. Type: string | int.
+	RequestID json.RawMessage `json:"requestId"`
+	Reason    *string         `json:"reason,omitempty"`
+}
+
+// NotificationsCancelled is called when the client sends the
+// "notifications/cancelled" notification. It cancels the context of the
+// request, which stops e.g. a running Postgres query. Unknown or already
+// completed requests are ignored.
+func (s *Server) NotificationsCancelled(ctx context.Context, p json.RawMessage) {
+	var params CancelledNotificationParams
+	err := json.Unmarshal(p, &params)
+	if err != nil || params.RequestID == nil {
+		log.Printf("Invalid cancelled notification params: %s", p)
+		return
+	}
+
+	if jsonrpc.CancelRequest(ctx, params.RequestID) {
+		reason := ""
+		if params.Reason != nil {
+			reason = *params.Reason
+		}
+		s.session(ctx).log(ctx, LevelInfo, "session", fmt.Sprintf("Cancelled request %s: %s", params.RequestID, reason))
+	}
 }
@@ -0,0 +1,483 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+const (
+	initializeRequest       = `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+	initializedNotification = `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+)
+
+// serve runs a server over stdio on the given lines and returns the responses
+// keyed by request ID. Requests are handled one at a time, in order.
+func serve(t *testing.T, lines ...string) map[string]jsonrpc.Response {
+	t.Helper()
+	return serveWith(t, Options{}, nil, lines...)
+}
+
+// clientInput returns the input of a stdio transport sending lines, and a
+// function that starts sending them once the server exists. Like a real
+// client, it sends the line after an initialize request only once the server
+// handled the request, since notifications don't wait for earlier requests.
+func clientInput(lines ...string) (io.Reader, func(s *Server)) {
+	r, w := io.Pipe()
+	start := func(s *Server) {
+		go func() {
+			defer w.Close()
+			for _, line := range lines {
+				io.WriteString(w, line+"\n")
+				if strings.Contains(line, `"method":"initialize"`) {
+					waitInitializing(s)
+				}
+			}
+		}()
+	}
+	return r, start
+}
+
+// waitInitializing waits up to a few seconds until the server handled the
+// initialize request of the stdio session.
+func waitInitializing(s *Server) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.sessionsMu.Lock()
+		sess := s.sessions["stdio"]
+		s.sessionsMu.Unlock()
+		if sess != nil && sess.State() != StateUninitialized {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// serveWith is like serve, but creates the server with opts and passes it to
+// setup, if not nil, before serving.
+func serveWith(t *testing.T, opts Options, setup func(s *Server), lines ...string) map[string]jsonrpc.Response {
+	t.Helper()
+	out := &bytes.Buffer{}
+	in, start := clientInput(lines...)
+	transport := jsonrpc.NewStdioServer(in, out, io.Discard)
+	transport.MaxConcurrent = 1
+
+	s, err := NewServer(transport, opts)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if setup != nil {
+		setup(s)
+	}
+	start(s)
+	transport.Serve()
+
+	resps := make(map[string]jsonrpc.Response)
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var resp jsonrpc.Response
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		resps[string(resp.ID)] = resp
+	}
+	return resps
+}
+
+// testClient talks to a server over stdio pipes.
+type testClient struct {
+	t      *testing.T
+	server *Server
+	in     io.Writer
+	dec    *json.Decoder
+}
+
+func newTestClient(t *testing.T) *testClient {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	transport := jsonrpc.NewStdioServer(inR, outW, io.Discard)
+
+	s, err := NewServer(transport, Options{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	s.AddTool(taskTool{})
+
+	done := make(chan struct{})
+	go func() {
+		transport.Serve()
+		outW.Close()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		io.Copy(io.Discard, outR)
+		<-done
+	})
+
+	c := &testClient{t: t, server: s, in: inW, dec: json.NewDecoder(outR)}
+	c.send(initializeRequest)
+	c.response(`"init"`)
+	c.send(initializedNotification)
+	c.waitReady()
+	return c
+}
+
+// waitReady waits until the server handled the initialized notification, so
+// that the session receives notifications.
+func (c *testClient) waitReady() {
+	c.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.server.sessionsMu.Lock()
+		sess := c.server.sessions["stdio"]
+		c.server.sessionsMu.Unlock()
+		if sess != nil && sess.State() == StateReady {
+			return
+		}
+		if time.Now().After(deadline) {
+			c.t.Fatal("session not ready after initialized notification")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (c *testClient) send(line string) {
+	io.WriteString(c.in, line+"\n")
+}
+
+// response reads messages until the response with the given ID, skipping
+// notifications.
+func (c *testClient) response(id string) jsonrpc.Response {
+	c.t.Helper()
+	for {
+		var resp jsonrpc.Response
+		if err := c.dec.Decode(&resp); err != nil {
+			c.t.Fatalf("Decode: %v", err)
+		}
+		if string(resp.ID) == id {
+			return resp
+		}
+	}
+}
+
+// result reads the response with the given ID and unmarshals its result into
+// v.
+func (c *testClient) result(id string, v any) {
+	c.t.Helper()
+	resp := c.response(id)
+	if resp.Error != nil {
+		c.t.Fatalf("response %s: unexpected error: %v", id, resp.Error)
+	}
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		c.t.Fatalf("Unmarshal: %v", err)
+	}
+}
+
+func TestRequestBeforeInitialize(t *testing.T) {
+	resps := serve(t, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+
+	resp := resps["1"]
+	if resp.Error == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if resp.Error.Code != jsonrpc.CodeInvalidRequest {
+		t.Errorf("Error.Code = %d, want %d", resp.Error.Code, jsonrpc.CodeInvalidRequest)
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	resps := serve(t,
+		initializeRequest,
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+	)
+
+	init := resps[`"init"`]
+	if init.Error != nil {
+		t.Fatalf("initialize: unexpected error: %v", init.Error)
+	}
+	var result InitializeResult
+	if err := json.Unmarshal(init.Result, &result); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if result.ProtocolVersion != ProtocolVersion {
+		t.Errorf("ProtocolVersion = %q, want %q", result.ProtocolVersion, ProtocolVersion)
+	}
+	if tools := result.Capabilities.Tools; tools == nil || tools.ListChanged == nil || !*tools.ListChanged {
+		t.Errorf("Capabilities.Tools = %+v, want listChanged", tools)
+	}
+	if resources := result.Capabilities.Resources; resources == nil {
+		t.Error("Capabilities.Resources = nil, want resources capability")
+	}
+	if prompts := result.Capabilities.Prompts; prompts == nil || prompts.ListChanged == nil || !*prompts.ListChanged {
+		t.Errorf("Capabilities.Prompts = %+v, want listChanged", prompts)
+	}
+
+	if resps["1"].Error != nil {
+		t.Errorf("tools/list: unexpected error: %v", resps["1"].Error)
+	}
+}
+
+func TestInitializeTwice(t *testing.T) {
+	resps := serve(t,
+		initializeRequest,
+		initializedNotification,
+		strings.Replace(initializeRequest, `"id":"init"`, `"id":2`, 1),
+	)
+
+	if resps[`"init"`].Error != nil {
+		t.Fatalf("first initialize: unexpected error: %v", resps[`"init"`].Error)
+	}
+	resp := resps["2"]
+	if resp.Error == nil {
+		t.Fatal("second initialize: expected error, got nil")
+	}
+	if resp.Error.Code != jsonrpc.CodeInvalidRequest {
+		t.Errorf("Error.Code = %d, want %d", resp.Error.Code, jsonrpc.CodeInvalidRequest)
+	}
+}
+
+func TestInitializeRecordsClient(t *testing.T) {
+	out := &bytes.Buffer{}
+	in, start := clientInput(initializeRequest, initializedNotification)
+	transport := jsonrpc.NewStdioServer(in, out, io.Discard)
+	transport.MaxConcurrent = 1
+
+	s, err := NewServer(transport, Options{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	// Capture the session before the transport ends it.
+	var sess *session
+	transport.RegisterNotification("notifications/initialized", func(ctx context.Context, p json.RawMessage) {
+		s.NotificationsInitialized(ctx, p)
+		sess = s.session(ctx)
+	})
+	start(s)
+	transport.Serve()
+
+	if sess == nil {
+		t.Fatal("initialized notification was not handled")
+	}
+	if got := sess.ClientInfo().Name; got != "test" {
+		t.Errorf("ClientInfo().Name = %q, want %q", got, "test")
+	}
+	if sess.Capabilities().CanElicitForm() {
+		t.Error("Capabilities().CanElicitForm() = true, want false")
+	}
+	if got := sess.State(); got != StateReady && got != StateShuttingDown {
+		t.Errorf("State() = %s, want ready or shutting down", got)
+	}
+}
+
+func TestInitializeNegotiatesVersion(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"2025-11-25", "2025-11-25"},
+		{"2025-06-18", "2025-06-18"},
+		{"2025-03-26", "2025-03-26"},
+		{"2024-11-05", ProtocolVersion},
+		{"unknown", ProtocolVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requested, func(t *testing.T) {
+			resps := serve(t, strings.Replace(initializeRequest, ProtocolVersion, tt.requested, 1))
+
+			init := resps[`"init"`]
+			if init.Error != nil {
+				t.Fatalf("initialize: unexpected error: %v", init.Error)
+			}
+			var result InitializeResult
+			if err := json.Unmarshal(init.Result, &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if result.ProtocolVersion != tt.want {
+				t.Errorf("ProtocolVersion = %q, want %q", result.ProtocolVersion, tt.want)
+			}
+		})
+	}
+}
+
+func TestListToolsGatedOnVersion(t *testing.T) {
+	tests := []struct {
+		version   string
+		wantTitle bool
+	}{
+		{"2025-11-25", true},
+		{"2025-06-18", true},
+		{"2025-03-26", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			resps := serve(t,
+				strings.Replace(initializeRequest, ProtocolVersion, tt.version, 1),
+				initializedNotification,
+				`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+			)
+
+			resp := resps["1"]
+			if resp.Error != nil {
+				t.Fatalf("tools/list: unexpected error: %v", resp.Error)
+			}
+			var result ListToolsResult
+			if err := json.Unmarshal(resp.Result, &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			for _, tool := range result.Tools {
+				if got := tool.Title != nil; got != tt.wantTitle {
+					t.Errorf("tool %s: has title = %v, want %v", tool.Name, got, tt.wantTitle)
+				}
+			}
+		})
+	}
+}
+
+func TestPingBeforeInitialize(t *testing.T) {
+	resps := serve(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+
+	resp := resps["1"]
+	if resp.Error != nil {
+		t.Fatalf("ping: unexpected error: %v", resp.Error)
+	}
+	if string(resp.Result) != "{}" {
+		t.Errorf("Result = %s, want {}", resp.Result)
+	}
+}
+
+func TestKeepAliveClosesUnresponsiveSession(t *testing.T) {
+	inR, inW := io.Pipe()
+	defer inW.Close()
+	out := &bytes.Buffer{}
+	transport := jsonrpc.NewStdioServer(inR, out, io.Discard)
+
+	s, err := NewServer(transport, Options{KeepAlive: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- transport.Serve()
+	}()
+
+	// The client initializes and then never answers the server's pings.
+	io.WriteString(inW, initializeRequest+"\n")
+	waitInitializing(s)
+	io.WriteString(inW, initializedNotification+"\n")
+
+	select {
+	case err := <-errCh:
+		if err != jsonrpc.ErrSessionClosed {
+			t.Errorf("Serve() = %v, want %v", err, jsonrpc.ErrSessionClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session was not closed")
+	}
+
+	if !strings.Contains(out.String(), `"method":"ping"`) {
+		t.Errorf("output has no ping request: %s", out.String())
+	}
+}
+
+func TestKeepAliveClosesIdleHTTPSession(t *testing.T) {
+	transport := jsonrpc.NewHTTPServer("", io.Discard)
+	_, err := NewServer(transport, Options{KeepAlive: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(transport)
+	defer ts.Close()
+
+	post := func(sessionID, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		if sessionID != "" {
+			req.Header.Set(jsonrpc.SessionIDHeader, sessionID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// The client initializes and never opens a GET stream, so it can't be
+	// pinged.
+	id := post("", initializeRequest).Header.Get(jsonrpc.SessionIDHeader)
+	post(id, initializedNotification)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		if post(id, `{"jsonrpc":"2.0","id":1,"method":"ping"}`).StatusCode == http.StatusNotFound {
+			return
+		}
+	}
+	t.Fatal("idle session was not closed")
+}
+
+func TestListToolsOrder(t *testing.T) {
+	out := &bytes.Buffer{}
+	lines := []string{
+		initializeRequest,
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+	}
+	in, start := clientInput(lines...)
+	transport := jsonrpc.NewStdioServer(in, out, io.Discard)
+	transport.MaxConcurrent = 1
+
+	s, err := NewServer(transport, Options{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	s.AddTool(failingTool{})
+	s.AddTool(countingTool{})
+	start(s)
+	transport.Serve()
+
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var resp jsonrpc.Response
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if string(resp.ID) == `"init"` {
+			continue
+		}
+
+		var result ListToolsResult
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		var names []string
+		for _, tool := range result.Tools {
+			names = append(names, tool.Name)
+		}
+		if want := []string{"calculator", "fail", "count"}; !slices.Equal(names, want) {
+			t.Errorf("response %s: tools = %v, want %v", resp.ID, names, want)
+		}
+	}
+}
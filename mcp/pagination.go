@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+// DefaultPageSize is the number of items list methods return per page when
+// Options.PageSize is zero.
+const DefaultPageSize = 100
+
+// PaginatedParams contains the parameters shared by list requests.
+type PaginatedParams struct {
+	// Cursor is the NextCursor of the previous page. The first page is
+	// requested without a cursor.
+	Cursor *string `json:"cursor,omitempty"`
+}
+
+// PaginatedResult contains the fields shared by list results.
+type PaginatedResult struct {
+	// NextCursor is set if there are more items after this page.
+	NextCursor *string `json:"nextCursor,omitempty"`
+}
+
+// encodeCursor returns the opaque cursor of the page starting at offset.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset encoded in cursor.
+func decodeCursor(cursor string) (int, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+
+	offset, err := strconv.Atoi(string(b))
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+	return offset, true
+}
+
+// paginate returns the page of items selected by the cursor in the list
+// request params p, and the result fields pointing at the next page. items
+// must be in a stable order. A cursor past the end of items, e.g. because
+// items were removed, selects an empty last page.
+func paginate[T any](items []T, p json.RawMessage, pageSize int) ([]T, PaginatedResult, *jsonrpc.Error) {
+	var params PaginatedParams
+	if len(p) > 0 {
+		err := json.Unmarshal(p, &params)
+		if err != nil {
+			return nil, PaginatedResult{}, &jsonrpc.Error{
+				Code:    jsonrpc.CodeInvalidParams,
+				Message: "Invalid params",
+			}
+		}
+	}
+
+	offset := 0
+	if params.Cursor != nil {
+		var ok bool
+		offset, ok = decodeCursor(*params.Cursor)
+		if !ok {
+			return nil, PaginatedResult{}, &jsonrpc.Error{
+				Code:    jsonrpc.CodeInvalidParams,
+				Message: "Invalid cursor",
+			}
+		}
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	start := min(offset, len(items))
+	end := min(start+pageSize, len(items))
+
+	var result PaginatedResult
+	if end < len(items) {
+		cursor := encodeCursor(end)
+		result.NextCursor = &cursor
+	}
+
+	return items[start:end], result, nil
+}
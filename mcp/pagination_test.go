@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+
+	var got []string
+	var params json.RawMessage
+	for pages := 0; ; pages++ {
+		if pages > len(items) {
+			t.Fatal("pagination did not end")
+		}
+
+		page, result, err := paginate(items, params, 2)
+		if err != nil {
+			t.Fatalf("paginate: %v", err)
+		}
+		got = append(got, page...)
+		if result.NextCursor == nil {
+			break
+		}
+		params, _ = json.Marshal(PaginatedParams{Cursor: result.NextCursor})
+	}
+
+	if !slices.Equal(got, items) {
+		t.Errorf("items = %v, want %v", got, items)
+	}
+}
+
+func TestPaginateCursor(t *testing.T) {
+	items := []int{1, 2, 3}
+
+	tests := []struct {
+		name     string
+		params   string
+		want     []int
+		wantNext bool
+		wantErr  bool
+	}{
+		{"no params", ``, []int{1, 2}, true, false},
+		{"no cursor", `{}`, []int{1, 2}, true, false},
+		{"last page", `{"cursor":"` + encodeCursor(2) + `"}`, []int{3}, false, false},
+		{"past the end", `{"cursor":"` + encodeCursor(10) + `"}`, []int{}, false, false},
+		{"not base64", `{"cursor":"!!"}`, nil, false, true},
+		{"not an offset", `{"cursor":"eHl6"}`, nil, false, true},
+		{"negative offset", `{"cursor":"` + encodeCursor(-1) + `"}`, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, result, err := paginate(items, json.RawMessage(tt.params), 2)
+			if tt.wantErr {
+				if err == nil || err.Code != jsonrpc.CodeInvalidParams {
+					t.Errorf("error = %v, want code %d", err, jsonrpc.CodeInvalidParams)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("paginate: %v", err)
+			}
+			if !slices.Equal(page, tt.want) {
+				t.Errorf("page = %v, want %v", page, tt.want)
+			}
+			if got := result.NextCursor != nil; got != tt.wantNext {
+				t.Errorf("has next cursor = %v, want %v", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestListToolsInvalidCursor(t *testing.T) {
+	resps := serve(t,
+		initializeRequest,
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{"cursor":"bogus!"}}`,
+	)
+
+	resp := resps["1"]
+	if resp.Error == nil || resp.Error.Code != jsonrpc.CodeInvalidParams {
+		t.Errorf("Error = %v, want code %d", resp.Error, jsonrpc.CodeInvalidParams)
+	}
+}
@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/types"
+)
+
+// RequestMeta is the _meta object of request params.
+type RequestMeta struct {
+	// ProgressToken is set if the client wants progress notifications for
+	// the request. Type: string | int.
+	ProgressToken json.RawMessage `json:"progressToken,omitempty"`
+}
+
+// ProgressNotificationParams contains parameters for a
+// notifications/progress notification.
+type ProgressNotificationParams struct {
+	// ProgressToken is the token of the request the progress is reported for.
+	ProgressToken json.RawMessage `json:"progressToken"`
+	// Progress increases with every notification, even if Total is unknown.
+	Progress float64 `json:"progress"`
+	// Total is the total amount of work, if known.
+	Total   *float64 `json:"total,omitempty"`
+	Message *string  `json:"message,omitempty"`
+}
+
+// ProgressReporter sends progress notifications for a request. A nil
+// ProgressReporter discards the progress, so tools can report unconditionally.
+type ProgressReporter struct {
+	session jsonrpc.Session
+	token   json.RawMessage
+
+	mu   sync.Mutex
+	last float64
+	sent bool
+}
+
+type progressContextKey struct{}
+
+// withProgress returns a copy of ctx carrying a ProgressReporter for the
+// request with the given progress token.
+func withProgress(ctx context.Context, token json.RawMessage) context.Context {
+	session := jsonrpc.SessionFromContext(ctx)
+	if session == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, progressContextKey{}, &ProgressReporter{
+		session: session,
+		token:   token,
+	})
+}
+
+// ProgressFromContext returns the ProgressReporter of the request in ctx. It
+// returns nil if the client did not ask for progress notifications.
+func ProgressFromContext(ctx context.Context) *ProgressReporter {
+	r, _ := ctx.Value(progressContextKey{}).(*ProgressReporter)
+	return r
+}
+
+// Report sends a progress notification. A total of 0 means the total is
+// unknown. Progress that does not exceed the previously reported progress is
+// dropped, as the spec requires it to increase.
+func (r *ProgressReporter) Report(ctx context.Context, progress, total float64, message string) {
+	if r == nil {
+		return
+	}
+
+	// The notification is sent under the lock, so that concurrent reports
+	// reach the client in increasing order.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sent && progress <= r.last {
+		return
+	}
+	r.last = progress
+	r.sent = true
+
+	params := ProgressNotificationParams{
+		ProgressToken: r.token,
+		Progress:      progress,
+	}
+	if total > 0 {
+		params.Total = &total
+	}
+	if message != "" {
+		params.Message = &message
+	}
+
+	err := r.session.Notify(ctx, "notifications/progress", types.NewRawJSON(params))
+	if err != nil {
+		log.Printf("Failed to send progress notification: %v", err)
+	}
+}
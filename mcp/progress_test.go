@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+// countingTool reports progress 1, 1 and 2 out of 2.
+type countingTool struct{}
+
+func (countingTool) Definition() Tool {
+	return Tool{Name: "count"}
+}
+
+func (countingTool) Execute(ctx context.Context, params json.RawMessage) (*CallToolResult, *jsonrpc.Error) {
+	progress := ProgressFromContext(ctx)
+	progress.Report(ctx, 1, 2, "one")
+	progress.Report(ctx, 1, 2, "one again")
+	progress.Report(ctx, 2, 2, "")
+	return NewTextResult("done"), nil
+}
+
+func TestProgress(t *testing.T) {
+	lines := []string{
+		initializeRequest,
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"count"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"count","_meta":{"progressToken":"tok"}}}`,
+	}
+	out := &bytes.Buffer{}
+	in, start := clientInput(lines...)
+	transport := jsonrpc.NewStdioServer(in, out, io.Discard)
+	transport.MaxConcurrent = 1
+
+	s, err := NewServer(transport, Options{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	s.AddTool(countingTool{})
+	start(s)
+	transport.Serve()
+
+	var notifications []ProgressNotificationParams
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var msg jsonrpc.Request
+		if err := dec.Decode(&msg); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if msg.Method != "notifications/progress" {
+			continue
+		}
+
+		var params ProgressNotificationParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		notifications = append(notifications, params)
+	}
+
+	if len(notifications) != 2 {
+		t.Fatalf("got %d progress notifications, want 2: %+v", len(notifications), notifications)
+	}
+	for i, want := range []float64{1, 2} {
+		n := notifications[i]
+		if string(n.ProgressToken) != `"tok"` {
+			t.Errorf("notification %d: ProgressToken = %s, want \"tok\"", i, n.ProgressToken)
+		}
+		if n.Progress != want {
+			t.Errorf("notification %d: Progress = %v, want %v", i, n.Progress, want)
+		}
+		if n.Total == nil || *n.Total != 2 {
+			t.Errorf("notification %d: Total = %v, want 2", i, n.Total)
+		}
+	}
+	if notifications[0].Message == nil || *notifications[0].Message != "one" {
+		t.Errorf("notification 0: Message = %v, want one", notifications[0].Message)
+	}
+	if notifications[1].Message != nil {
+		t.Errorf("notification 1: Message = %q, want nil", *notifications[1].Message)
+	}
+}
+
+// orderSession records the progress of the notifications sent on it. Sending
+// progress 1 waits a while for progress 2 to be sent first.
+type orderSession struct {
+	jsonrpc.Session
+
+	entered  chan struct{}
+	recorded chan struct{}
+
+	mu       sync.Mutex
+	progress []float64
+}
+
+func (s *orderSession) Notify(ctx context.Context, method string, params json.RawMessage) error {
+	var p ProgressNotificationParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+
+	s.entered <- struct{}{}
+	if p.Progress == 1 {
+		select {
+		case <-s.recorded:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	s.mu.Lock()
+	s.progress = append(s.progress, p.Progress)
+	s.mu.Unlock()
+	s.recorded <- struct{}{}
+	return nil
+}
+
+func TestProgressConcurrentReports(t *testing.T) {
+	session := &orderSession{entered: make(chan struct{}, 2), recorded: make(chan struct{}, 2)}
+	r := &ProgressReporter{session: session, token: json.RawMessage(`"tok"`)}
+
+	done := make(chan struct{})
+	go func() {
+		r.Report(context.Background(), 1, 0, "")
+		done <- struct{}{}
+	}()
+	// Progress 2 is reported while progress 1 is being sent.
+	<-session.entered
+	go func() {
+		r.Report(context.Background(), 2, 0, "")
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+
+	if !slices.Equal(session.progress, []float64{1, 2}) {
+		t.Errorf("progress = %v, want [1 2]", session.progress)
+	}
+}
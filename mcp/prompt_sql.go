@@ -0,0 +1,296 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/aphilas/pgmcp/pkg/types"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PromptMaxTables is the maximum number of table descriptions a prompt embeds.
+// Further tables are linked instead.
+const PromptMaxTables = 20
+
+// QueryPrompt asks the model about a query, e.g. to explain or optimize it.
+// The prompt includes the plan of the query and embeds the descriptions of the
+// tables the plan reads.
+type QueryPrompt struct {
+	Prompt Prompt
+	// Instruction is the request to the model, followed by the query.
+	Instruction string
+
+	DB *pgxpool.Pool
+	// Catalog returns the current catalog of the database.
+	Catalog func() *postgres.Catalog
+}
+
+// sqlArgument is the argument of the prompts about a query.
+var sqlArgument = PromptArgument{
+	Name:        "sql",
+	Title:       types.Ptr("SQL"),
+	Description: types.Ptr("The SQL query."),
+	Required:    true,
+}
+
+func NewExplainQueryPrompt(db *pgxpool.Pool, catalog func() *postgres.Catalog) *QueryPrompt {
+	return &QueryPrompt{
+		Prompt: Prompt{
+			Name:        "explain-query",
+			Title:       types.Ptr("Explain query"),
+			Description: types.Ptr("Explain what a SQL query does and how Postgres runs it."),
+			Arguments:   []PromptArgument{sqlArgument},
+		},
+		Instruction: "Explain what the following query does and how Postgres runs it, " +
+			"based on its plan and the tables it reads.",
+		DB:      db,
+		Catalog: catalog,
+	}
+}
+
+func NewOptimizeQueryPrompt(db *pgxpool.Pool, catalog func() *postgres.Catalog) *QueryPrompt {
+	return &QueryPrompt{
+		Prompt: Prompt{
+			Name:        "optimize-query",
+			Title:       types.Ptr("Optimize query"),
+			Description: types.Ptr("Suggest how to make a SQL query faster."),
+			Arguments:   []PromptArgument{sqlArgument},
+		},
+		Instruction: "Suggest how to make the following query faster, e.g. by rewriting it or adding indexes, " +
+			"based on its plan and the tables it reads. Explain why each suggestion helps.",
+		DB:      db,
+		Catalog: catalog,
+	}
+}
+
+func (p *QueryPrompt) Definition() Prompt {
+	return p.Prompt
+}
+
+func (p *QueryPrompt) Get(ctx context.Context, args map[string]string) (*GetPromptResult, *jsonrpc.Error) {
+	sql := args["sql"]
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s\n\n```sql\n%s\n```\n\n", p.Instruction, sql)
+
+	var tables []postgres.TableName
+	plan, err := postgres.Explain(ctx, p.DB, sql)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr):
+		// The model can still help with a query Postgres rejects, e.g. by
+		// fixing a syntax error.
+		fmt.Fprintf(&text, "Postgres could not plan the query: %s", pgErr.Error())
+	case err != nil:
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: fmt.Sprintf("Error explaining query: %s", err.Error()),
+		}
+	default:
+		fmt.Fprintf(&text, "Plan:\n\n```\n%s\n```", plan.Text)
+		tables = plan.Tables
+	}
+
+	messages := []PromptMessage{{Role: RoleUser, Content: NewTextContent(text.String())}}
+	tableMessages, tableErr := tablePromptMessages(ctx, p.DB, p.Catalog().Database, tables)
+	if tableErr != nil {
+		return nil, tableErr
+	}
+
+	return &GetPromptResult{
+		Description: p.Prompt.Description,
+		Messages:    append(messages, tableMessages...),
+	}, nil
+}
+
+// DescribeTablePrompt asks the model to describe a table. The prompt embeds the
+// description of the table and a sample of its rows.
+type DescribeTablePrompt struct {
+	Prompt Prompt
+
+	DB *pgxpool.Pool
+	// Catalog returns the current catalog of the database.
+	Catalog func() *postgres.Catalog
+}
+
+func NewDescribeTablePrompt(db *pgxpool.Pool, catalog func() *postgres.Catalog) *DescribeTablePrompt {
+	return &DescribeTablePrompt{
+		Prompt: Prompt{
+			Name:        "describe-table",
+			Title:       types.Ptr("Describe table"),
+			Description: types.Ptr("Describe what a table holds and how it relates to other tables."),
+			Arguments: []PromptArgument{{
+				Name:        "table",
+				Title:       types.Ptr("Table"),
+				Description: types.Ptr("The table, optionally qualified with its schema, e.g. public.film."),
+				Required:    true,
+			}},
+		},
+		DB:      db,
+		Catalog: catalog,
+	}
+}
+
+func (p *DescribeTablePrompt) Definition() Prompt {
+	return p.Prompt
+}
+
+func (p *DescribeTablePrompt) Get(ctx context.Context, args map[string]string) (*GetPromptResult, *jsonrpc.Error) {
+	catalog := p.Catalog()
+	table, resolveErr := resolveTable(catalog, args["table"])
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	text := fmt.Sprintf("Describe what the table %s holds, what its columns mean and how it relates to other tables, "+
+		"based on its definition and a sample of its rows.", table)
+	messages := []PromptMessage{{Role: RoleUser, Content: NewTextContent(text)}}
+
+	tableMessages, tableErr := tablePromptMessages(ctx, p.DB, catalog.Database, []postgres.TableName{table})
+	if tableErr != nil {
+		return nil, tableErr
+	}
+	messages = append(messages, tableMessages...)
+
+	uri := TableSampleURI(catalog.Database, table)
+	sample, err := postgres.SampleRows(ctx, p.DB, table, TableSampleDefaultLimit)
+	if err != nil {
+		return nil, rowsReadError(uri, err)
+	}
+	contents, contentsErr := rowsContents(uri, sample)
+	if contentsErr != nil {
+		return nil, contentsErr
+	}
+	messages = append(messages, PromptMessage{Role: RoleUser, Content: NewEmbeddedResource(contents[0])})
+
+	return &GetPromptResult{
+		Description: p.Prompt.Description,
+		Messages:    messages,
+	}, nil
+}
+
+// resolveTable finds the table named name, which may be qualified with its
+// schema, in catalog. An unqualified name that is in several schemas resolves
+// to the table in the public schema.
+func resolveTable(catalog *postgres.Catalog, name string) (postgres.TableName, *jsonrpc.Error) {
+	schema, table, qualified := strings.Cut(name, ".")
+	if !qualified {
+		schema, table = "", name
+	}
+
+	var matches []postgres.TableName
+	for _, t := range catalog.Tables {
+		if t.Name == table && (schema == "" || t.Schema == schema) {
+			matches = append(matches, t)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return postgres.TableName{}, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Table not found: " + name,
+		}
+	case 1:
+		return matches[0], nil
+	}
+
+	for _, t := range matches {
+		if t.Schema == "public" {
+			return t, nil
+		}
+	}
+	return postgres.TableName{}, &jsonrpc.Error{
+		Code:    jsonrpc.CodeInvalidParams,
+		Message: fmt.Sprintf("Table %s is in several schemas; qualify it with the schema", name),
+	}
+}
+
+// WriteQueryPrompt asks the model to write a query answering a question. The
+// prompt embeds the descriptions of the tables of the database, up to
+// PromptMaxTables, and links the rest.
+type WriteQueryPrompt struct {
+	Prompt Prompt
+
+	DB *pgxpool.Pool
+	// Catalog returns the current catalog of the database.
+	Catalog func() *postgres.Catalog
+}
+
+func NewWriteQueryPrompt(db *pgxpool.Pool, catalog func() *postgres.Catalog) *WriteQueryPrompt {
+	return &WriteQueryPrompt{
+		Prompt: Prompt{
+			Name:        "write-query",
+			Title:       types.Ptr("Write query"),
+			Description: types.Ptr("Write a SQL query answering a question about the data."),
+			Arguments: []PromptArgument{{
+				Name:        "question",
+				Title:       types.Ptr("Question"),
+				Description: types.Ptr("The question the query should answer."),
+				Required:    true,
+			}},
+		},
+		DB:      db,
+		Catalog: catalog,
+	}
+}
+
+func (p *WriteQueryPrompt) Definition() Prompt {
+	return p.Prompt
+}
+
+func (p *WriteQueryPrompt) Get(ctx context.Context, args map[string]string) (*GetPromptResult, *jsonrpc.Error) {
+	catalog := p.Catalog()
+
+	text := fmt.Sprintf("Write a read-only Postgres query answering the following question, "+
+		"using the tables described below. Run it with the query tool to check the answer.\n\n%s", args["question"])
+	messages := []PromptMessage{{Role: RoleUser, Content: NewTextContent(text)}}
+
+	tableMessages, tableErr := tablePromptMessages(ctx, p.DB, catalog.Database, catalog.Tables)
+	if tableErr != nil {
+		return nil, tableErr
+	}
+
+	return &GetPromptResult{
+		Description: p.Prompt.Description,
+		Messages:    append(messages, tableMessages...),
+	}, nil
+}
+
+// tablePromptMessages returns a message embedding the text description of each
+// table, up to PromptMaxTables, and a message linking the resource of each
+// further table. Tables that don't exist, e.g. catalog tables in a plan, are
+// skipped.
+func tablePromptMessages(ctx context.Context, db *pgxpool.Pool, database string, tables []postgres.TableName) ([]PromptMessage, *jsonrpc.Error) {
+	var messages []PromptMessage
+	embedded := 0
+	for _, table := range tables {
+		resource := NewTableResource(db, database, table)
+		if embedded == PromptMaxTables {
+			messages = append(messages, PromptMessage{Role: RoleUser, Content: NewResourceLink(resource.Definition())})
+			continue
+		}
+
+		contents, err := resource.Read(ctx)
+		if err != nil && err.Code == CodeResourceNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range contents {
+			if c.MimeType != nil && *c.MimeType == "text/plain" {
+				messages = append(messages, PromptMessage{Role: RoleUser, Content: NewEmbeddedResource(c)})
+				embedded++
+			}
+		}
+	}
+
+	return messages, nil
+}
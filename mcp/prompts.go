@@ -0,0 +1,183 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+type Prompter interface {
+	Definition() Prompt
+	// Get returns the prompt filled in with the arguments. The server checks
+	// that the required arguments are present. ctx is cancelled if the client
+	// cancels the request.
+	Get(ctx context.Context, args map[string]string) (*GetPromptResult, *jsonrpc.Error)
+}
+
+// Prompt describes a prompt template the client can get. Omitted: icons,
+// _meta.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Title       *string          `json:"title,omitempty"`
+	Description *string          `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument describes an argument of a prompt template.
+type PromptArgument struct {
+	Name        string  `json:"name"`
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+}
+
+// PromptsCapability is present if the server offers prompts.
+type PromptsCapability struct {
+	// ListChanged indicates whether the server notifies the client when the
+	// list of prompts changes.
+	ListChanged *bool `json:"listChanged,omitempty"`
+}
+
+// ListPromptsParams contains parameters for a prompts/list request.
+type ListPromptsParams struct {
+	PaginatedParams
+}
+
+// ListPromptsResult is the server's response to a prompts/list request.
+type ListPromptsResult struct {
+	Prompts []Prompt `json:"prompts"`
+	PaginatedResult
+}
+
+// GetPromptParams contains parameters for a prompts/get request.
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// GetPromptResult is the server's response to a prompts/get request.
+type GetPromptResult struct {
+	Description *string         `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptMessage is a message of a prompt.
+type PromptMessage struct {
+	Role    Role    `json:"role"`
+	Content Content `json:"content"`
+}
+
+// AddPrompt registers a prompt, replacing any prompt with the same name, and
+// tells connected clients that the list of prompts changed. It is safe to call
+// while the server is running.
+func (s *Server) AddPrompt(prompt Prompter) {
+	s.prompts.add(prompt.Definition().Name, prompt)
+	s.notifyAll("notifications/prompts/list_changed", nil)
+}
+
+// RemovePrompt unregisters the prompt with the given name and tells connected
+// clients that the list of prompts changed. It reports whether there was such
+// a prompt.
+func (s *Server) RemovePrompt(name string) bool {
+	if !s.prompts.remove(name) {
+		return false
+	}
+
+	s.notifyAll("notifications/prompts/list_changed", nil)
+	return true
+}
+
+// ListPrompts is called when the client sends the "prompts/list" request. It
+// returns a page of the prompts the server offers, in registration order.
+func (s *Server) ListPrompts(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	page, cursor, pageErr := paginate(s.prompts.list(), p, s.pageSize)
+	if pageErr != nil {
+		return nil, pageErr
+	}
+
+	structured := s.session(ctx).Supports(versionStructuredContent)
+
+	prompts := ListPromptsResult{
+		Prompts:         make([]Prompt, 0, len(page)),
+		PaginatedResult: cursor,
+	}
+	for _, prompt := range page {
+		definition := prompt.Definition()
+		if !structured {
+			definition.Title = nil
+			arguments := make([]PromptArgument, len(definition.Arguments))
+			for i, argument := range definition.Arguments {
+				argument.Title = nil
+				arguments[i] = argument
+			}
+			definition.Arguments = arguments
+		}
+		prompts.Prompts = append(prompts.Prompts, definition)
+	}
+
+	resultBytes, err := json.Marshal(prompts)
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal prompts list",
+		}
+	}
+
+	return resultBytes, nil
+}
+
+// GetPrompt is called when the client sends the "prompts/get" request. It
+// returns the prompt filled in with the provided arguments.
+func (s *Server) GetPrompt(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	var params GetPromptParams
+	err := json.Unmarshal(p, &params)
+	if err != nil {
+		log.Printf("Failed to unmarshal GetPrompt params: %v", err)
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Invalid params",
+		}
+	}
+
+	prompt, ok := s.prompts.get(params.Name)
+	if !ok {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Prompt not found",
+		}
+	}
+
+	for _, argument := range prompt.Definition().Arguments {
+		if argument.Required && params.Arguments[argument.Name] == "" {
+			return nil, &jsonrpc.Error{
+				Code:    jsonrpc.CodeInvalidParams,
+				Message: "Missing required argument " + argument.Name,
+			}
+		}
+	}
+
+	result, getErr := prompt.Get(ctx, params.Arguments)
+	if getErr != nil {
+		Log(ctx, LevelError, params.Name, getErr.Message)
+		return nil, getErr
+	}
+
+	// Clients that predate resource links don't know them.
+	if !s.session(ctx).Supports(versionStructuredContent) {
+		for i, message := range result.Messages {
+			result.Messages[i].Content = linksAsText([]Content{message.Content})[0]
+		}
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal prompt",
+		}
+	}
+
+	return resultBytes, nil
+}
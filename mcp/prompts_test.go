@@ -0,0 +1,156 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/aphilas/pgmcp/pkg/types"
+)
+
+// greetingPrompt asks the model to greet someone, linking a resource about
+// them.
+type greetingPrompt struct{}
+
+func (greetingPrompt) Definition() Prompt {
+	return Prompt{
+		Name:  "greeting",
+		Title: types.Ptr("Greeting"),
+		Arguments: []PromptArgument{
+			{Name: "name", Title: types.Ptr("Name"), Required: true},
+			{Name: "tone"},
+		},
+	}
+}
+
+func (greetingPrompt) Get(ctx context.Context, args map[string]string) (*GetPromptResult, *jsonrpc.Error) {
+	return &GetPromptResult{
+		Messages: []PromptMessage{
+			{Role: RoleUser, Content: NewTextContent("Greet " + args["name"])},
+			{Role: RoleUser, Content: NewResourceLink(Resource{URI: "test://" + args["name"], Name: args["name"]})},
+		},
+	}, nil
+}
+
+func TestListPrompts(t *testing.T) {
+	tests := []struct {
+		version   string
+		wantTitle bool
+	}{
+		{ProtocolVersion, true},
+		{"2025-03-26", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			resps := serveWith(t, Options{}, func(s *Server) { s.AddPrompt(greetingPrompt{}) },
+				`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"`+tt.version+`","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`,
+				initializedNotification,
+				`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`,
+			)
+
+			var result ListPromptsResult
+			if err := json.Unmarshal(resps["1"].Result, &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if len(result.Prompts) != 1 || result.Prompts[0].Name != "greeting" {
+				t.Fatalf("Prompts = %+v, want greeting", result.Prompts)
+			}
+			prompt := result.Prompts[0]
+			if got := prompt.Title != nil && prompt.Arguments[0].Title != nil; got != tt.wantTitle {
+				t.Errorf("has titles = %v, want %v", got, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestGetPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   string
+		wantCode int
+	}{
+		{"ok", `{"name":"greeting","arguments":{"name":"ada"}}`, 0},
+		{"missing argument", `{"name":"greeting","arguments":{"tone":"warm"}}`, jsonrpc.CodeInvalidParams},
+		{"unknown prompt", `{"name":"missing"}`, jsonrpc.CodeInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resps := serveWith(t, Options{}, func(s *Server) { s.AddPrompt(greetingPrompt{}) },
+				initializeRequest,
+				initializedNotification,
+				`{"jsonrpc":"2.0","id":1,"method":"prompts/get","params":`+tt.params+`}`,
+			)
+
+			resp := resps["1"]
+			if tt.wantCode != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.wantCode {
+					t.Errorf("Error = %v, want code %d", resp.Error, tt.wantCode)
+				}
+				return
+			}
+
+			var result GetPromptResult
+			if err := json.Unmarshal(resp.Result, &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if len(result.Messages) != 2 || result.Messages[0].Content != NewTextContent("Greet ada") {
+				t.Errorf("Messages = %+v, want the greeting", result.Messages)
+			}
+			if _, ok := result.Messages[1].Content.(ResourceLink); !ok {
+				t.Errorf("Messages[1].Content = %T, want ResourceLink", result.Messages[1].Content)
+			}
+		})
+	}
+}
+
+func TestPromptsListChanged(t *testing.T) {
+	c := newTestClient(t)
+
+	c.server.AddPrompt(greetingPrompt{})
+	if n := c.notification(); n.Method != "notifications/prompts/list_changed" {
+		t.Errorf("Method = %q, want notifications/prompts/list_changed", n.Method)
+	}
+
+	if !c.server.RemovePrompt("greeting") {
+		t.Error("RemovePrompt = false, want true")
+	}
+	if n := c.notification(); n.Method != "notifications/prompts/list_changed" {
+		t.Errorf("Method = %q, want notifications/prompts/list_changed", n.Method)
+	}
+}
+
+func TestResolveTable(t *testing.T) {
+	catalog := &postgres.Catalog{
+		Tables: []postgres.TableName{
+			{Schema: "public", Name: "film"},
+			{Schema: "archive", Name: "film"},
+			{Schema: "archive", Name: "rental"},
+			{Schema: "a", Name: "staff"},
+			{Schema: "b", Name: "staff"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		want    postgres.TableName
+		wantErr bool
+	}{
+		{"film", postgres.TableName{Schema: "public", Name: "film"}, false},
+		{"archive.film", postgres.TableName{Schema: "archive", Name: "film"}, false},
+		{"rental", postgres.TableName{Schema: "archive", Name: "rental"}, false},
+		{"public.rental", postgres.TableName{}, true},
+		{"staff", postgres.TableName{}, true},
+		{"missing", postgres.TableName{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveTable(catalog, tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolveTable(%q) = %v, %v, want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"slices"
+	"sync"
+)
+
+// registry holds named items, such as tools, in registration order so that
+// list methods return them in a stable order. It is safe for concurrent use.
+type registry[T any] struct {
+	mu    sync.RWMutex
+	names []string
+	items map[string]T
+}
+
+func newRegistry[T any]() *registry[T] {
+	return &registry[T]{
+		items: make(map[string]T),
+	}
+}
+
+// add registers item under name. An item that replaces one with the same name
+// keeps its position.
+func (r *registry[T]) add(name string, item T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.items[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.items[name] = item
+}
+
+// get returns the item registered under name.
+func (r *registry[T]) get(name string) (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.items[name]
+	return item, ok
+}
+
+// list returns the items in registration order.
+func (r *registry[T]) list() []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := make([]T, 0, len(r.names))
+	for _, name := range r.names {
+		items = append(items, r.items[name])
+	}
+	return items
+}
+
+// remove unregisters the item with the given name. It reports whether there
+// was such an item.
+func (r *registry[T]) remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.items[name]; !ok {
+		return false
+	}
+
+	delete(r.items, name)
+	r.names = slices.DeleteFunc(r.names, func(n string) bool {
+		return n == name
+	})
+	return true
+}
@@ -0,0 +1,25 @@
+package mcp
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRegistryOrder(t *testing.T) {
+	r := newRegistry[int]()
+	r.add("c", 1)
+	r.add("a", 2)
+	r.add("b", 3)
+	// Replacing an item keeps its position.
+	r.add("c", 4)
+
+	if got, want := r.list(), []int{4, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("list() = %v, want %v", got, want)
+	}
+	if got, ok := r.get("a"); !ok || got != 2 {
+		t.Errorf("get(a) = %v, %v, want 2, true", got, ok)
+	}
+	if _, ok := r.get("z"); ok {
+		t.Error("get(z) found an item")
+	}
+}
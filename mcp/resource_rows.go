@@ -0,0 +1,226 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/aphilas/pgmcp/pkg/types"
+	"github.com/aphilas/pgmcp/pkg/uritemplate"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// rowsTemplate is implemented by the templates of resources showing rows of a
+// table. Their template variables include schema and table.
+type rowsTemplate interface {
+	// database returns the name of the database whose tables the template
+	// reads.
+	database() string
+	// changedBy reports whether change affects the resource with the given
+	// template variables.
+	changedBy(vars map[string]string, change postgres.Change) bool
+}
+
+// tableFromVars returns the table named by the variables of a rowsTemplate.
+func tableFromVars(vars map[string]string) postgres.TableName {
+	return postgres.TableName{Schema: vars["schema"], Name: vars["table"]}
+}
+
+// TableSampleDefaultLimit is the number of rows a table sample has if the URI
+// doesn't set a limit.
+const TableSampleDefaultLimit = 10
+
+// tableSampleURITemplate is the URI template of TableSampleTemplate.
+var tableSampleURITemplate = uritemplate.MustParse("postgres://{db}/{schema}/{table}/sample{?limit}")
+
+// TableSampleURI returns the URI of the default sample of a table.
+func TableSampleURI(database string, table postgres.TableName) string {
+	return tableSampleURITemplate.Expand(map[string]string{
+		"db":     database,
+		"schema": table.Schema,
+		"table":  table.Name,
+	})
+}
+
+// TableSampleTemplate reads the first rows of a table:
+// postgres://{db}/{schema}/{table}/sample{?limit}. The limit is at most
+// QueryMaxRows.
+type TableSampleTemplate struct {
+	ResourceTemplate ResourceTemplate
+
+	DB       *pgxpool.Pool
+	Database string
+}
+
+func NewTableSampleTemplate(db *pgxpool.Pool, database string) *TableSampleTemplate {
+	return &TableSampleTemplate{
+		ResourceTemplate: ResourceTemplate{
+			URITemplate: tableSampleURITemplate.String(),
+			Name:        "table-sample",
+			Title:       types.Ptr("Table sample"),
+			Description: types.Ptr(fmt.Sprintf(
+				"The first rows of a table of database %s, %d unless limit is set.",
+				database, TableSampleDefaultLimit,
+			)),
+			MimeType: types.Ptr("application/json"),
+		},
+		DB:       db,
+		Database: database,
+	}
+}
+
+func (t *TableSampleTemplate) Definition() ResourceTemplate {
+	return t.ResourceTemplate
+}
+
+func (t *TableSampleTemplate) Read(ctx context.Context, uri string, vars map[string]string) ([]ResourceContents, *jsonrpc.Error) {
+	if vars["db"] != t.Database {
+		return nil, resourceNotFound(uri)
+	}
+
+	limit := TableSampleDefaultLimit
+	if v, ok := vars["limit"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > QueryMaxRows {
+			return nil, &jsonrpc.Error{
+				Code:    jsonrpc.CodeInvalidParams,
+				Message: fmt.Sprintf("Invalid limit: must be a number from 0 to %d", QueryMaxRows),
+			}
+		}
+		limit = n
+	}
+
+	result, err := postgres.SampleRows(ctx, t.DB, tableFromVars(vars), limit)
+	if err != nil {
+		return nil, rowsReadError(uri, err)
+	}
+
+	return rowsContents(uri, result)
+}
+
+func (t *TableSampleTemplate) database() string {
+	return t.Database
+}
+
+// changedBy reports whether the change is to the sampled table. Any change may
+// change the sample.
+func (t *TableSampleTemplate) changedBy(vars map[string]string, change postgres.Change) bool {
+	return vars["db"] == t.Database && tableFromVars(vars) == change.TableName()
+}
+
+// TableRowTemplate reads a row of a table by primary key:
+// postgres://{db}/{schema}/{table}/row/{pk*}. The values of a composite key
+// are separated by commas, in key order; commas in a value are
+// percent-encoded.
+type TableRowTemplate struct {
+	ResourceTemplate ResourceTemplate
+
+	DB       *pgxpool.Pool
+	Database string
+}
+
+func NewTableRowTemplate(db *pgxpool.Pool, database string) *TableRowTemplate {
+	return &TableRowTemplate{
+		ResourceTemplate: ResourceTemplate{
+			URITemplate: "postgres://{db}/{schema}/{table}/row/{pk*}",
+			Name:        "table-row",
+			Title:       types.Ptr("Table row"),
+			Description: types.Ptr(fmt.Sprintf(
+				"A row of a table of database %s by primary key. The values of a composite key are separated by commas; commas in a value are percent-encoded.",
+				database,
+			)),
+			MimeType: types.Ptr("application/json"),
+		},
+		DB:       db,
+		Database: database,
+	}
+}
+
+func (t *TableRowTemplate) Definition() ResourceTemplate {
+	return t.ResourceTemplate
+}
+
+func (t *TableRowTemplate) Read(ctx context.Context, uri string, vars map[string]string) ([]ResourceContents, *jsonrpc.Error) {
+	if vars["db"] != t.Database {
+		return nil, resourceNotFound(uri)
+	}
+
+	key, err := uritemplate.SplitList(vars["pk"])
+	if err != nil {
+		return nil, resourceNotFound(uri)
+	}
+
+	result, err := postgres.RowByPrimaryKey(ctx, t.DB, tableFromVars(vars), key)
+	if err != nil {
+		return nil, rowsReadError(uri, err)
+	}
+
+	return rowsContents(uri, result)
+}
+
+func (t *TableRowTemplate) database() string {
+	return t.Database
+}
+
+// changedBy reports whether the change is to the row, or to an unknown row of
+// its table.
+func (t *TableRowTemplate) changedBy(vars map[string]string, change postgres.Change) bool {
+	if vars["db"] != t.Database || tableFromVars(vars) != change.TableName() {
+		return false
+	}
+	if len(change.Key) == 0 {
+		return true
+	}
+	key, err := uritemplate.SplitList(vars["pk"])
+	return err == nil && slices.Equal(key, change.Key)
+}
+
+// rowsContents returns result as the JSON contents of the resource uri.
+func rowsContents(uri string, result *postgres.Result) ([]ResourceContents, *jsonrpc.Error) {
+	structured, err := json.Marshal(result)
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal rows",
+		}
+	}
+
+	return []ResourceContents{{
+		URI:      uri,
+		MimeType: types.Ptr("application/json"),
+		Text:     types.Ptr(string(structured)),
+	}}, nil
+}
+
+// rowsReadError converts an error reading rows for the resource uri to a
+// protocol error. Missing tables and rows are not found; keys that don't
+// parse as values of the key columns are invalid params.
+func rowsReadError(uri string, err error) *jsonrpc.Error {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, postgres.ErrTableNotFound), errors.Is(err, postgres.ErrRowNotFound):
+		return resourceNotFound(uri)
+	case errors.As(err, &pgErr) && pgErr.Code == "42P01":
+		// undefined_table
+		return resourceNotFound(uri)
+	case errors.Is(err, postgres.ErrNoPrimaryKey), errors.Is(err, postgres.ErrInvalidKey),
+		errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "22"):
+		// Class 22 is data exceptions, e.g. invalid_text_representation.
+		return &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: err.Error(),
+		}
+	}
+
+	return &jsonrpc.Error{
+		Code:    jsonrpc.CodeInternalError,
+		Message: fmt.Sprintf("Error reading rows: %s", err.Error()),
+	}
+}
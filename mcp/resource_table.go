@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/aphilas/pgmcp/pkg/types"
+	"github.com/aphilas/pgmcp/pkg/uritemplate"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// tableURITemplate expands to the URIs of table resources. Expanding a
+// template encodes the names the way template matching decodes them.
+var tableURITemplate = uritemplate.MustParse("postgres://{db}/schemas/{schema}/tables/{table}")
+
+// TableURI returns the URI of the resource describing a table:
+// postgres://<database>/schemas/<schema>/tables/<table>.
+func TableURI(database string, table postgres.TableName) string {
+	return tableURITemplate.Expand(map[string]string{
+		"db":     database,
+		"schema": table.Schema,
+		"table":  table.Name,
+	})
+}
+
+// TableResource describes the columns, constraints and indexes of a table.
+// Its contents are the description as JSON and as text.
+type TableResource struct {
+	Resource Resource
+
+	DB    *pgxpool.Pool
+	Table postgres.TableName
+}
+
+func NewTableResource(db *pgxpool.Pool, database string, table postgres.TableName) *TableResource {
+	return &TableResource{
+		Resource: Resource{
+			URI:         TableURI(database, table),
+			Name:        table.String(),
+			Title:       types.Ptr("Table " + table.String()),
+			Description: types.Ptr(fmt.Sprintf("Columns, constraints and indexes of %s.", table)),
+			MimeType:    types.Ptr("application/json"),
+		},
+		DB:    db,
+		Table: table,
+	}
+}
+
+func (r *TableResource) Definition() Resource {
+	return r.Resource
+}
+
+func (r *TableResource) Read(ctx context.Context) ([]ResourceContents, *jsonrpc.Error) {
+	table, err := postgres.DescribeTable(ctx, r.DB, r.Table)
+	if errors.Is(err, postgres.ErrTableNotFound) {
+		return nil, resourceNotFound(r.Resource.URI)
+	}
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: fmt.Sprintf("Error describing table: %s", err.Error()),
+		}
+	}
+
+	structured, err := json.Marshal(table)
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal table description",
+		}
+	}
+
+	return []ResourceContents{
+		{
+			URI:      r.Resource.URI,
+			MimeType: types.Ptr("application/json"),
+			Text:     types.Ptr(string(structured)),
+		},
+		{
+			URI:      r.Resource.URI,
+			MimeType: types.Ptr("text/plain"),
+			Text:     types.Ptr(table.String()),
+		},
+	}, nil
+}
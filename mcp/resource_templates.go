@@ -0,0 +1,124 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/uritemplate"
+)
+
+type ResourceTemplater interface {
+	Definition() ResourceTemplate
+	// Read returns the contents of the resource with the given URI, which
+	// matches the template. vars holds the values of the template variables
+	// in uri. ctx is cancelled if the client cancels the request.
+	Read(ctx context.Context, uri string, vars map[string]string) ([]ResourceContents, *jsonrpc.Error)
+}
+
+// ResourceTemplate describes resources the client can read by expanding an
+// RFC 6570 URI template. Omitted: icons, _meta.
+type ResourceTemplate struct {
+	URITemplate string       `json:"uriTemplate"`
+	Name        string       `json:"name"`
+	Title       *string      `json:"title,omitempty"`
+	Description *string      `json:"description,omitempty"`
+	MimeType    *string      `json:"mimeType,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+}
+
+// ListResourceTemplatesParams contains parameters for a
+// resources/templates/list request.
+type ListResourceTemplatesParams struct {
+	PaginatedParams
+}
+
+// ListResourceTemplatesResult is the server's response to a
+// resources/templates/list request.
+type ListResourceTemplatesResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+	PaginatedResult
+}
+
+// registeredTemplate is a resource template offered by the server.
+type registeredTemplate struct {
+	ResourceTemplater
+	template *uritemplate.Template
+}
+
+// AddResourceTemplate registers a resource template, replacing any template
+// with the same URI template, and tells connected clients that the list of
+// resources changed. It is safe to call while the server is running.
+func (s *Server) AddResourceTemplate(template ResourceTemplater) error {
+	definition := template.Definition()
+
+	parsed, err := uritemplate.Parse(definition.URITemplate)
+	if err != nil {
+		return fmt.Errorf("parsing URI template of resource template %s: %w", definition.Name, err)
+	}
+
+	s.templates.add(definition.URITemplate, &registeredTemplate{
+		ResourceTemplater: template,
+		template:          parsed,
+	})
+	s.notifyAll("notifications/resources/list_changed", nil)
+	return nil
+}
+
+// RemoveResourceTemplate unregisters the resource template with the given URI
+// template and tells connected clients that the list of resources changed. It
+// reports whether there was such a template.
+func (s *Server) RemoveResourceTemplate(uriTemplate string) bool {
+	if !s.templates.remove(uriTemplate) {
+		return false
+	}
+
+	s.notifyAll("notifications/resources/list_changed", nil)
+	return true
+}
+
+// matchTemplate returns the first registered template matching uri and the
+// values of its variables.
+func (s *Server) matchTemplate(uri string) (*registeredTemplate, map[string]string, bool) {
+	for _, template := range s.templates.list() {
+		if vars, ok := template.template.Match(uri); ok {
+			return template, vars, true
+		}
+	}
+	return nil, nil, false
+}
+
+// ListResourceTemplates is called when the client sends the
+// "resources/templates/list" request. It returns a page of the resource
+// templates the server offers, in registration order.
+func (s *Server) ListResourceTemplates(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	page, cursor, pageErr := paginate(s.templates.list(), p, s.pageSize)
+	if pageErr != nil {
+		return nil, pageErr
+	}
+
+	structured := s.session(ctx).Supports(versionStructuredContent)
+
+	templates := ListResourceTemplatesResult{
+		ResourceTemplates: make([]ResourceTemplate, 0, len(page)),
+		PaginatedResult:   cursor,
+	}
+	for _, template := range page {
+		definition := template.Definition()
+		if !structured {
+			definition.Title = nil
+		}
+		templates.ResourceTemplates = append(templates.ResourceTemplates, definition)
+	}
+
+	resultBytes, err := json.Marshal(templates)
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal resource templates list",
+		}
+	}
+
+	return resultBytes, nil
+}
@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/types"
+)
+
+// greetingTemplate greets the name in the URI.
+type greetingTemplate struct{}
+
+func (greetingTemplate) Definition() ResourceTemplate {
+	return ResourceTemplate{URITemplate: "test://greeting/{name}{?punctuation}", Name: "greeting"}
+}
+
+func (greetingTemplate) Read(ctx context.Context, uri string, vars map[string]string) ([]ResourceContents, *jsonrpc.Error) {
+	text := "Hello " + vars["name"] + vars["punctuation"]
+	return []ResourceContents{{URI: uri, Text: types.Ptr(text)}}, nil
+}
+
+func TestListResourceTemplates(t *testing.T) {
+	resps := serveWith(t, Options{}, func(s *Server) {
+		if err := s.AddResourceTemplate(greetingTemplate{}); err != nil {
+			t.Fatalf("AddResourceTemplate: %v", err)
+		}
+	},
+		initializeRequest,
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"resources/templates/list"}`,
+	)
+
+	var result ListResourceTemplatesResult
+	if err := json.Unmarshal(resps["1"].Result, &result); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(result.ResourceTemplates) != 1 || result.ResourceTemplates[0].Name != "greeting" {
+		t.Errorf("ResourceTemplates = %+v, want greeting", result.ResourceTemplates)
+	}
+}
+
+func TestReadResourceTemplate(t *testing.T) {
+	tests := []struct {
+		uri      string
+		want     string
+		wantCode int
+	}{
+		{"test://greeting/World", "Hello World", 0},
+		{"test://greeting/J%C3%BCrgen?punctuation=%21", "Hello Jürgen!", 0},
+		// Resources take precedence over templates.
+		{"test://greeting/static", "static", 0},
+		{"test://greeting/World?unknown=1", "", CodeResourceNotFound},
+		{"test://greeting/a/b", "", CodeResourceNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			resps := serveWith(t, Options{}, func(s *Server) {
+				s.AddResource(textResource{uri: "test://greeting/static", text: "static"})
+				if err := s.AddResourceTemplate(greetingTemplate{}); err != nil {
+					t.Fatalf("AddResourceTemplate: %v", err)
+				}
+			},
+				initializeRequest,
+				initializedNotification,
+				fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":%q}}`, tt.uri),
+			)
+
+			resp := resps["1"]
+			if tt.wantCode != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.wantCode {
+					t.Fatalf("Error = %v, want code %d", resp.Error, tt.wantCode)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Fatalf("unexpected error: %v", resp.Error)
+			}
+			var result ReadResourceResult
+			if err := json.Unmarshal(resp.Result, &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if len(result.Contents) != 1 || result.Contents[0].Text == nil || *result.Contents[0].Text != tt.want {
+				t.Errorf("Contents = %+v, want text %q", result.Contents, tt.want)
+			}
+		})
+	}
+}
+
+// badTemplate has an invalid URI template.
+type badTemplate struct{ greetingTemplate }
+
+func (badTemplate) Definition() ResourceTemplate {
+	return ResourceTemplate{URITemplate: "test://{unterminated", Name: "bad"}
+}
+
+func TestAddResourceTemplateInvalid(t *testing.T) {
+	s, err := NewServer(jsonrpc.NewStdioServer(nil, nil, nil), Options{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := s.AddResourceTemplate(badTemplate{}); err == nil {
+		t.Error("AddResourceTemplate: expected error, got nil")
+	}
+}
@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+// CodeResourceNotFound is the error code of a resources/read request for a
+// resource that does not exist.
+const CodeResourceNotFound = -32002
+
+// resourceNotFound returns the error of a resources/read request for a
+// resource that does not exist.
+func resourceNotFound(uri string) *jsonrpc.Error {
+	return &jsonrpc.Error{
+		Code:    CodeResourceNotFound,
+		Message: "Resource not found",
+		Data:    jsonrpc.JSONRawMessage(map[string]string{"uri": uri}),
+	}
+}
+
+type Resourcer interface {
+	Definition() Resource
+	// Read returns the contents of the resource. ctx is cancelled if the
+	// client cancels the request.
+	Read(ctx context.Context) ([]ResourceContents, *jsonrpc.Error)
+}
+
+// ResourcesCapability is present if the server offers resources.
+type ResourcesCapability struct {
+	// Subscribe indicates whether the client can subscribe to updates of
+	// individual resources.
+	Subscribe *bool `json:"subscribe,omitempty"`
+	// ListChanged indicates whether the server notifies the client when the
+	// list of resources changes.
+	ListChanged *bool `json:"listChanged,omitempty"`
+}
+
+// ListResourcesParams contains parameters for a resources/list request.
+type ListResourcesParams struct {
+	PaginatedParams
+}
+
+// ListResourcesResult is the server's response to a resources/list request.
+type ListResourcesResult struct {
+	Resources []Resource `json:"resources"`
+	PaginatedResult
+}
+
+// ReadResourceParams contains parameters for a resources/read request.
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ReadResourceResult is the server's response to a resources/read request.
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// AddResource registers a resource, replacing any resource with the same URI,
+// and tells connected clients that the list of resources changed. It is safe
+// to call while the server is running.
+func (s *Server) AddResource(resource Resourcer) {
+	s.resources.add(resource.Definition().URI, resource)
+	s.notifyAll("notifications/resources/list_changed", nil)
+}
+
+// RemoveResource unregisters the resource with the given URI and tells
+// connected clients that the list of resources changed. It reports whether
+// there was such a resource.
+func (s *Server) RemoveResource(uri string) bool {
+	if !s.resources.remove(uri) {
+		return false
+	}
+
+	s.notifyAll("notifications/resources/list_changed", nil)
+	return true
+}
+
+// ListResources is called when the client sends the "resources/list" request.
+// It returns a page of the resources the server offers, in registration
+// order.
+func (s *Server) ListResources(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	page, cursor, pageErr := paginate(s.resources.list(), p, s.pageSize)
+	if pageErr != nil {
+		return nil, pageErr
+	}
+
+	structured := s.session(ctx).Supports(versionStructuredContent)
+
+	resources := ListResourcesResult{
+		Resources:       make([]Resource, 0, len(page)),
+		PaginatedResult: cursor,
+	}
+	for _, resource := range page {
+		definition := resource.Definition()
+		if !structured {
+			definition.Title = nil
+		}
+		resources.Resources = append(resources.Resources, definition)
+	}
+
+	resultBytes, err := json.Marshal(resources)
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal resources list",
+		}
+	}
+
+	return resultBytes, nil
+}
+
+// ReadResource is called when the client sends the "resources/read" request.
+// It returns the contents of the resource with the requested URI, or else of
+// the first resource template matching the URI.
+func (s *Server) ReadResource(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	var params ReadResourceParams
+	err := json.Unmarshal(p, &params)
+	if err != nil || params.URI == "" {
+		log.Printf("Failed to unmarshal ReadResource params: %v", err)
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Invalid params",
+		}
+	}
+
+	var contents []ResourceContents
+	var readErr *jsonrpc.Error
+	if resource, ok := s.resources.get(params.URI); ok {
+		contents, readErr = resource.Read(ctx)
+	} else if template, vars, ok := s.matchTemplate(params.URI); ok {
+		contents, readErr = template.Read(ctx, params.URI, vars)
+	} else {
+		return nil, resourceNotFound(params.URI)
+	}
+	if readErr != nil {
+		Log(ctx, LevelError, "resources", map[string]any{
+			"message": "Reading resource failed",
+			"uri":     params.URI,
+			"error":   readErr.Message,
+		})
+		return nil, readErr
+	}
+
+	resultBytes, err := json.Marshal(ReadResourceResult{Contents: contents})
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal resource contents",
+		}
+	}
+
+	return resultBytes, nil
+}
@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/aphilas/pgmcp/pkg/types"
+	"github.com/aphilas/pgmcp/pkg/uritemplate"
+)
+
+// textResource is a resource with fixed text contents.
+type textResource struct {
+	uri  string
+	text string
+}
+
+func (r textResource) Definition() Resource {
+	return Resource{URI: r.uri, Name: r.uri, Title: types.Ptr("Text " + r.uri)}
+}
+
+func (r textResource) Read(ctx context.Context) ([]ResourceContents, *jsonrpc.Error) {
+	return []ResourceContents{{URI: r.uri, Text: types.Ptr(r.text)}}, nil
+}
+
+func TestListResources(t *testing.T) {
+	uris := []string{"test://c", "test://a", "test://b"}
+	resps := serveWith(t, Options{PageSize: 2}, func(s *Server) {
+		for _, uri := range uris {
+			s.AddResource(textResource{uri: uri})
+		}
+	},
+		initializeRequest,
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`,
+	)
+
+	var result ListResourcesResult
+	if err := json.Unmarshal(resps["1"].Result, &result); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(result.Resources) != 2 || result.Resources[0].URI != uris[0] || result.Resources[1].URI != uris[1] {
+		t.Errorf("Resources = %+v, want the first two of %v", result.Resources, uris)
+	}
+	if result.NextCursor == nil {
+		t.Error("NextCursor = nil, want a cursor")
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	tests := []struct {
+		uri      string
+		wantCode int
+	}{
+		{"test://a", 0},
+		{"test://missing", CodeResourceNotFound},
+		{"", jsonrpc.CodeInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			resps := serveWith(t, Options{}, func(s *Server) {
+				s.AddResource(textResource{uri: "test://a", text: "hello"})
+			},
+				initializeRequest,
+				initializedNotification,
+				fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":%q}}`, tt.uri),
+			)
+
+			resp := resps["1"]
+			if tt.wantCode != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.wantCode {
+					t.Fatalf("Error = %v, want code %d", resp.Error, tt.wantCode)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Fatalf("unexpected error: %v", resp.Error)
+			}
+			var result ReadResourceResult
+			if err := json.Unmarshal(resp.Result, &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if len(result.Contents) != 1 || result.Contents[0].Text == nil || *result.Contents[0].Text != "hello" {
+				t.Errorf("Contents = %+v, want text hello", result.Contents)
+			}
+		})
+	}
+}
+
+func TestResourceListChanged(t *testing.T) {
+	c := newTestClient(t)
+
+	c.server.AddResource(textResource{uri: "test://a"})
+	if n := c.notification(); n.Method != "notifications/resources/list_changed" {
+		t.Errorf("Method = %q, want notifications/resources/list_changed", n.Method)
+	}
+
+	if !c.server.RemoveResource("test://a") {
+		t.Fatal("RemoveResource(test://a) = false, want true")
+	}
+	if n := c.notification(); n.Method != "notifications/resources/list_changed" {
+		t.Errorf("Method = %q, want notifications/resources/list_changed", n.Method)
+	}
+	if c.server.RemoveResource("test://a") {
+		t.Error("second RemoveResource(test://a) = true, want false")
+	}
+}
+
+func TestTableURI(t *testing.T) {
+	tests := []struct {
+		database string
+		table    postgres.TableName
+		want     string
+	}{
+		{"dvdrental", postgres.TableName{Schema: "public", Name: "actor"}, "postgres://dvdrental/schemas/public/tables/actor"},
+		{"my db", postgres.TableName{Schema: "a/b", Name: "c d"}, "postgres://my%20db/schemas/a%2Fb/tables/c%20d"},
+		{"db", postgres.TableName{Schema: "x:y", Name: "a+b"}, "postgres://db/schemas/x%3Ay/tables/a%2Bb"},
+	}
+
+	for _, tt := range tests {
+		if got := TableURI(tt.database, tt.table); got != tt.want {
+			t.Errorf("TableURI(%q, %v) = %q, want %q", tt.database, tt.table, got, tt.want)
+		}
+	}
+}
+
+func TestTableSampleURIMatchesTemplate(t *testing.T) {
+	template := NewTableSampleTemplate(nil, "db")
+	parsed := uritemplate.MustParse(template.Definition().URITemplate)
+
+	for _, name := range []string{"actor", "a+b", "a&b=c", "user@host:1", "c d", "a/b"} {
+		table := postgres.TableName{Schema: "public", Name: name}
+		uri := TableSampleURI("db", table)
+		vars, ok := parsed.Match(uri)
+		if !ok {
+			t.Errorf("TableSampleURI(%v) = %q, which the template doesn't match", table, uri)
+			continue
+		}
+		if got := tableFromVars(vars); got != table {
+			t.Errorf("table of %q = %v, want %v", uri, got, table)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+// SessionState is the lifecycle stage of a session.
+// See: https://modelcontextprotocol.io/specification/2025-11-25/basic/lifecycle
+type SessionState int
+
+const (
+	// StateUninitialized is the state of a session before the initialize
+	// request. Only initialize and ping are allowed.
+	StateUninitialized SessionState = iota
+	// StateInitializing is the state after the server answered initialize and
+	// before the client sent notifications/initialized.
+	StateInitializing
+	// StateReady is the state of normal operation.
+	StateReady
+	// StateShuttingDown is the state once the transport closed the session.
+	// In-flight requests may still be finishing; new ones are rejected.
+	StateShuttingDown
+)
+
+func (st SessionState) String() string {
+	switch st {
+	case StateUninitialized:
+		return "uninitialized"
+	case StateInitializing:
+		return "initializing"
+	case StateReady:
+		return "ready"
+	case StateShuttingDown:
+		return "shutting down"
+	}
+	return "unknown"
+}
+
+// session holds what the server knows about a connected client.
+type session struct {
+	jsonrpc.Session
+
+	mu              sync.Mutex
+	state           SessionState
+	protocolVersion string
+	clientInfo      Implementation
+	// capabilities are the client capabilities sent with initialize.
+	capabilities ClientCapabilities
+	// logLevel is the minimum level of the log messages sent to the client.
+	// No messages are sent until the client sets a level.
+	logLevel LoggingLevel
+	// subscriptions holds the URIs of the resources the client subscribed
+	// to.
+	subscriptions map[string]bool
+	// lastActive is when the client last sent a message or a request of it
+	// finished. inFlight is the number of its requests being handled.
+	lastActive time.Time
+	inFlight   int
+}
+
+type sessionContextKey struct{}
+
+// sessionFromContext returns the session added to ctx by requireInitialized,
+// or nil.
+func sessionFromContext(ctx context.Context) *session {
+	sess, _ := ctx.Value(sessionContextKey{}).(*session)
+	return sess
+}
+
+// State returns the lifecycle state of the session.
+func (sess *session) State() SessionState {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.state
+}
+
+// ClientInfo returns the client implementation sent with initialize.
+func (sess *session) ClientInfo() Implementation {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.clientInfo
+}
+
+// Capabilities returns the client capabilities sent with initialize.
+func (sess *session) Capabilities() ClientCapabilities {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.capabilities
+}
+
+// ProtocolVersion returns the protocol version negotiated at initialization.
+func (sess *session) ProtocolVersion() string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.protocolVersion
+}
+
+// Supports reports whether the negotiated protocol version is at least
+// version, i.e. whether the client understands the features it introduced.
+func (sess *session) Supports(version string) bool {
+	return sess.ProtocolVersion() >= version
+}
+
+// touch records activity of the client.
+func (sess *session) touch() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.lastActive = time.Now()
+}
+
+// idle reports whether the client has no request in flight and has been
+// inactive for at least d.
+func (sess *session) idle(d time.Duration) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.inFlight == 0 && time.Since(sess.lastActive) >= d
+}
+
+// session returns the state of the session the request in ctx belongs to,
+// recording the activity of the client. The state is created on first use and
+// dropped when the transport ends the session.
+func (s *Server) session(ctx context.Context) *session {
+	transportSession := jsonrpc.SessionFromContext(ctx)
+	if transportSession == nil {
+		// Requests always arrive with a session; this only guards against a
+		// handler being called directly.
+		return &session{}
+	}
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	id := transportSession.ID()
+	sess, ok := s.sessions[id]
+	if ok {
+		sess.touch()
+		return sess
+	}
+
+	sess = &session{Session: transportSession, lastActive: time.Now()}
+
+	// Don't resurrect a session that has already ended.
+	select {
+	case <-transportSession.Done():
+		sess.state = StateShuttingDown
+		return sess
+	default:
+	}
+
+	s.sessions[id] = sess
+
+	if s.keepAlive > 0 {
+		go s.keepAliveSession(sess, s.keepAlive)
+	}
+
+	go func() {
+		<-transportSession.Done()
+
+		sess.mu.Lock()
+		sess.state = StateShuttingDown
+		sess.mu.Unlock()
+
+		s.sessionsMu.Lock()
+		delete(s.sessions, id)
+		s.sessionsMu.Unlock()
+
+		s.tasks.removeSession(id)
+	}()
+
+	return sess
+}
+
+// notifyAll sends a notification to every session that completed
+// initialization. Sessions that cannot receive it, e.g. HTTP clients without
+// an open stream, miss it.
+func (s *Server) notifyAll(method string, params json.RawMessage) {
+	s.notifySessions(method, params, nil)
+}
+
+// notifySessions is like notifyAll, but only notifies the sessions for which
+// filter, if not nil, returns true.
+func (s *Server) notifySessions(method string, params json.RawMessage, filter func(sess *session) bool) {
+	s.sessionsMu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionsMu.Unlock()
+
+	for _, sess := range sessions {
+		if sess.State() != StateReady || (filter != nil && !filter(sess)) {
+			continue
+		}
+
+		// Don't let a slow client hold up the others.
+		go func() {
+			err := sess.Notify(context.Background(), method, params)
+			if err != nil && !errors.Is(err, jsonrpc.ErrNoStream) && !errors.Is(err, jsonrpc.ErrConnClosed) {
+				log.Printf("Failed to send %s to session %s: %v", method, sess.ID(), err)
+			}
+		}()
+	}
+}
+
+// keepAliveSession pings the client every interval until the session ends. The
+// session is closed if the client does not answer a ping within the interval.
+// An HTTP client without an open stream can't be pinged; its session is closed
+// once it has been idle for the interval. Other failures are ignored.
+func (s *Server) keepAliveSession(sess *session, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-sess.Done():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		_, err := sess.Call(ctx, "ping", nil)
+		cancel()
+
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			// The client is not reading, so this is only logged locally.
+			log.Printf("Closing session %s: no answer to ping within %s", sess.ID(), interval)
+			sess.Close()
+			return
+		case errors.Is(err, jsonrpc.ErrNoStream) && sess.idle(interval):
+			log.Printf("Closing session %s: no stream and no requests within %s", sess.ID(), interval)
+			sess.Close()
+			return
+		}
+	}
+}
+
+// requireInitialized wraps method so that it is rejected until the session has
+// been initialized and once it is shutting down. The session is added to the
+// context of accepted requests.
+func (s *Server) requireInitialized(method jsonrpc.Method) jsonrpc.Method {
+	return func(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+		sess := s.session(ctx)
+		switch sess.State() {
+		case StateUninitialized:
+			return nil, &jsonrpc.Error{
+				Code:    jsonrpc.CodeInvalidRequest,
+				Message: "Server not initialized",
+			}
+		case StateShuttingDown:
+			return nil, &jsonrpc.Error{
+				Code:    jsonrpc.CodeInvalidRequest,
+				Message: "Session is shutting down",
+			}
+		}
+
+		sess.mu.Lock()
+		sess.inFlight++
+		sess.mu.Unlock()
+		defer func() {
+			sess.mu.Lock()
+			sess.inFlight--
+			sess.lastActive = time.Now()
+			sess.mu.Unlock()
+		}()
+
+		ctx = context.WithValue(ctx, sessionContextKey{}, sess)
+		return method(ctx, p)
+	}
+}
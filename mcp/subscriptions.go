@@ -0,0 +1,265 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/aphilas/pgmcp/pkg/types"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ListenRetryInterval is how long the server waits before reconnecting when
+// its connection listening for table changes fails.
+const ListenRetryInterval = 5 * time.Second
+
+// SubscribeRequestParams contains parameters for a resources/subscribe
+// request.
+type SubscribeRequestParams struct {
+	URI string `json:"uri"`
+}
+
+// UnsubscribeRequestParams contains parameters for a resources/unsubscribe
+// request.
+type UnsubscribeRequestParams struct {
+	URI string `json:"uri"`
+}
+
+// ResourceUpdatedNotificationParams contains parameters for a
+// notifications/resources/updated notification.
+type ResourceUpdatedNotificationParams struct {
+	URI string `json:"uri"`
+}
+
+// changeListener turns notifications of table changes into updates of the
+// resources showing rows of the tables.
+type changeListener struct {
+	db *pgxpool.Pool
+	// installTriggers enables installing change triggers on the tables of
+	// subscribed resources.
+	installTriggers bool
+
+	start sync.Once
+
+	mu sync.Mutex
+	// triggers holds the tables change triggers were installed on.
+	triggers map[postgres.TableName]bool
+}
+
+func newChangeListener(db *pgxpool.Pool, installTriggers bool) *changeListener {
+	return &changeListener{
+		db:              db,
+		installTriggers: installTriggers,
+		triggers:        make(map[postgres.TableName]bool),
+	}
+}
+
+// subscribe records that the session wants updates of the resource.
+func (sess *session) subscribe(uri string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.subscriptions == nil {
+		sess.subscriptions = make(map[string]bool)
+	}
+	sess.subscriptions[uri] = true
+}
+
+// unsubscribe stops the updates of the resource.
+func (sess *session) unsubscribe(uri string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	delete(sess.subscriptions, uri)
+}
+
+// subscribed reports whether the session wants updates of the resource.
+func (sess *session) subscribed(uri string) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.subscriptions[uri]
+}
+
+// Subscribe is called when the client sends the "resources/subscribe"
+// request. The client is sent notifications/resources/updated when the
+// resource changes, until it unsubscribes.
+//
+// Resources showing rows of a table change when a notification on
+// postgres.ChangeChannel names the table. Only tables of the connected
+// database in the catalog can be subscribed to. If the server installs
+// triggers, subscribing installs them on the table.
+func (s *Server) Subscribe(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	var params SubscribeRequestParams
+	err := json.Unmarshal(p, &params)
+	if err != nil || params.URI == "" {
+		log.Printf("Failed to unmarshal Subscribe params: %v", err)
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Invalid params",
+		}
+	}
+
+	_, isResource := s.resources.get(params.URI)
+	template, vars, isTemplate := s.matchTemplate(params.URI)
+	if !isResource && !isTemplate {
+		return nil, resourceNotFound(params.URI)
+	}
+
+	var rows rowsTemplate
+	if isTemplate {
+		rows, _ = template.ResourceTemplater.(rowsTemplate)
+	}
+	// Only tables of the database are watched, and have triggers installed.
+	if rows != nil && !s.hasTable(rows, vars) {
+		return nil, resourceNotFound(params.URI)
+	}
+
+	s.session(ctx).subscribe(params.URI)
+
+	if rows != nil && s.changes != nil {
+		s.startListening()
+		s.installChangeTrigger(ctx, tableFromVars(vars))
+	}
+
+	return jsonrpc.EmptyResult(), nil
+}
+
+// hasTable reports whether the variables of a rowsTemplate name the template's
+// database and, if the server has a catalog, a table in it.
+func (s *Server) hasTable(template rowsTemplate, vars map[string]string) bool {
+	if vars["db"] != template.database() {
+		return false
+	}
+	if s.catalog == nil {
+		return true
+	}
+
+	catalog := s.catalog.current()
+	return catalog != nil && slices.Contains(catalog.Tables, tableFromVars(vars))
+}
+
+// Unsubscribe is called when the client sends the "resources/unsubscribe"
+// request.
+func (s *Server) Unsubscribe(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	var params UnsubscribeRequestParams
+	err := json.Unmarshal(p, &params)
+	if err != nil || params.URI == "" {
+		log.Printf("Failed to unmarshal Unsubscribe params: %v", err)
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Invalid params",
+		}
+	}
+
+	s.session(ctx).unsubscribe(params.URI)
+	return jsonrpc.EmptyResult(), nil
+}
+
+// ResourceUpdated tells the clients subscribed to the resource with the given
+// URI that it changed. It is safe to call while the server is running.
+func (s *Server) ResourceUpdated(uri string) {
+	params := types.NewRawJSON(ResourceUpdatedNotificationParams{URI: uri})
+	s.notifySessions("notifications/resources/updated", params, func(sess *session) bool {
+		return sess.subscribed(uri)
+	})
+}
+
+// subscribedURIs returns the URIs of the resources any session subscribed to.
+func (s *Server) subscribedURIs() []string {
+	s.sessionsMu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionsMu.Unlock()
+
+	var uris []string
+	for _, sess := range sessions {
+		sess.mu.Lock()
+		for uri := range sess.subscriptions {
+			uris = append(uris, uri)
+		}
+		sess.mu.Unlock()
+	}
+
+	slices.Sort(uris)
+	return slices.Compact(uris)
+}
+
+// startListening starts listening for table and schema changes, once.
+func (s *Server) startListening() {
+	s.changes.start.Do(func() {
+		s.goBackground(s.listenChanges)
+	})
+}
+
+// listenChanges listens for table and schema changes until the server is
+// closed, reconnecting after ListenRetryInterval when the connection fails.
+func (s *Server) listenChanges() {
+	onDDL := func(tag string) {
+		s.requestCatalogRefresh()
+	}
+
+	for {
+		err := postgres.Listen(s.ctx, s.changes.db, s.tableChanged, onDDL)
+		if s.ctx.Err() != nil {
+			return
+		}
+		log.Printf("Listening for changes failed, retrying in %s: %v", ListenRetryInterval, err)
+
+		select {
+		case <-time.After(ListenRetryInterval):
+		case <-s.ctx.Done():
+			return
+		}
+		// Schema changes may have been missed.
+		s.requestCatalogRefresh()
+	}
+}
+
+// tableChanged sends updates of the subscribed resources the change affects.
+func (s *Server) tableChanged(change postgres.Change) {
+	for _, uri := range s.subscribedURIs() {
+		template, vars, ok := s.matchTemplate(uri)
+		if !ok {
+			continue
+		}
+		rows, ok := template.ResourceTemplater.(rowsTemplate)
+		if ok && rows.changedBy(vars, change) {
+			s.ResourceUpdated(uri)
+		}
+	}
+}
+
+// installChangeTrigger installs change triggers on the table, once, if the
+// server installs triggers. Failures, e.g. for lack of privileges, are only
+// logged: the client is still told about changes the application notifies.
+func (s *Server) installChangeTrigger(ctx context.Context, table postgres.TableName) {
+	if !s.changes.installTriggers {
+		return
+	}
+
+	s.changes.mu.Lock()
+	defer s.changes.mu.Unlock()
+
+	if s.changes.triggers[table] {
+		return
+	}
+
+	err := postgres.InstallChangeTrigger(ctx, s.changes.db, table)
+	if err != nil {
+		Log(ctx, LevelWarning, "resources", map[string]any{
+			"message": "Installing change trigger failed",
+			"table":   table.String(),
+			"error":   err.Error(),
+		})
+		return
+	}
+	s.changes.triggers[table] = true
+}
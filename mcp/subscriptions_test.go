@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/aphilas/pgmcp/pkg/uritemplate"
+)
+
+// updatedURI reads messages until a notifications/resources/updated
+// notification and returns its URI.
+func (c *testClient) updatedURI() string {
+	c.t.Helper()
+	n := c.notification()
+	for n.Method != "notifications/resources/updated" {
+		n = c.notification()
+	}
+	var params ResourceUpdatedNotificationParams
+	if err := json.Unmarshal(n.Params, &params); err != nil {
+		c.t.Fatalf("Unmarshal: %v", err)
+	}
+	return params.URI
+}
+
+func TestSubscribe(t *testing.T) {
+	c := newTestClient(t)
+	c.server.AddResource(textResource{uri: "test://a"})
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":{"uri":"test://missing"}}`)
+	if resp := c.response("1"); resp.Error == nil || resp.Error.Code != CodeResourceNotFound {
+		t.Errorf("subscribe to missing resource: Error = %v, want code %d", resp.Error, CodeResourceNotFound)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"test://a"}}`)
+	c.result("2", &struct{}{})
+
+	c.server.ResourceUpdated("test://other")
+	c.server.ResourceUpdated("test://a")
+	if uri := c.updatedURI(); uri != "test://a" {
+		t.Errorf("updated URI = %q, want test://a", uri)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"resources/unsubscribe","params":{"uri":"test://a"}}`)
+	c.result("3", &struct{}{})
+
+	c.server.ResourceUpdated("test://a")
+	c.send(`{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	var msg jsonrpc.Request
+	if err := c.dec.Decode(&msg); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if msg.ID == nil {
+		t.Errorf("got %s after unsubscribing, want the ping response", msg.Method)
+	}
+}
+
+func TestSubscribeUnknownTable(t *testing.T) {
+	c := newTestClient(t)
+	c.server.catalog = newCatalogWatcher(nil)
+	c.server.catalog.catalog = &postgres.Catalog{
+		Database: "db",
+		Tables:   []postgres.TableName{{Schema: "public", Name: "rental"}},
+	}
+	if err := c.server.AddResourceTemplate(NewTableRowTemplate(nil, "db")); err != nil {
+		t.Fatalf("AddResourceTemplate: %v", err)
+	}
+
+	tests := []struct {
+		uri     string
+		wantErr bool
+	}{
+		{"postgres://db/public/rental/row/1", false},
+		{"postgres://other/public/rental/row/1", true},
+		{"postgres://db/public/missing/row/1", true},
+		{"postgres://db/other/rental/row/1", true},
+	}
+
+	for i, tt := range tests {
+		id := strconv.Itoa(i + 1)
+		c.send(`{"jsonrpc":"2.0","id":` + id + `,"method":"resources/subscribe","params":{"uri":"` + tt.uri + `"}}`)
+		resp := c.response(id)
+		if gotErr := resp.Error != nil && resp.Error.Code == CodeResourceNotFound; gotErr != tt.wantErr {
+			t.Errorf("subscribe to %s: Error = %v, want resource not found %v", tt.uri, resp.Error, tt.wantErr)
+		}
+	}
+}
+
+func TestTableChanged(t *testing.T) {
+	c := newTestClient(t)
+	if err := c.server.AddResourceTemplate(NewTableRowTemplate(nil, "db")); err != nil {
+		t.Fatalf("AddResourceTemplate: %v", err)
+	}
+	if err := c.server.AddResourceTemplate(NewTableSampleTemplate(nil, "db")); err != nil {
+		t.Fatalf("AddResourceTemplate: %v", err)
+	}
+
+	uris := []string{
+		"postgres://db/public/rental/row/1",
+		"postgres://db/public/rental/row/2",
+		"postgres://db/public/rental/sample?limit=5",
+		"postgres://db/public/film/sample",
+	}
+	for i, uri := range uris {
+		id := strconv.Itoa(i + 1)
+		c.send(`{"jsonrpc":"2.0","id":` + id + `,"method":"resources/subscribe","params":{"uri":"` + uri + `"}}`)
+		c.result(id, &struct{}{})
+	}
+
+	c.server.tableChanged(postgres.Change{Schema: "public", Table: "rental", Op: "UPDATE", Key: []string{"2"}})
+
+	got := map[string]bool{c.updatedURI(): true, c.updatedURI(): true}
+	want := map[string]bool{uris[1]: true, uris[2]: true}
+	if len(got) != len(want) || !got[uris[1]] || !got[uris[2]] {
+		t.Errorf("updated URIs = %v, want %v", got, want)
+	}
+}
+
+func TestRowChangedByCompositeKey(t *testing.T) {
+	template := NewTableRowTemplate(nil, "db")
+	parsed := uritemplate.MustParse(template.Definition().URITemplate)
+
+	tests := []struct {
+		uri  string
+		key  []string
+		want bool
+	}{
+		{"postgres://db/public/film_actor/row/1,23", []string{"1", "23"}, true},
+		{"postgres://db/public/film_actor/row/1,23", []string{"1", "2"}, false},
+		{"postgres://db/public/film_actor/row/1%2C23", []string{"1", "23"}, false},
+		{"postgres://db/public/film_actor/row/a%2Cb", []string{"a,b"}, true},
+		{"postgres://db/public/film_actor/row/a%2Cb", []string{"a", "b"}, false},
+	}
+
+	for _, tt := range tests {
+		vars, ok := parsed.Match(tt.uri)
+		if !ok {
+			t.Errorf("Match(%q) = false, want true", tt.uri)
+			continue
+		}
+		change := postgres.Change{Schema: "public", Table: "film_actor", Op: "UPDATE", Key: tt.key}
+		if got := template.changedBy(vars, change); got != tt.want {
+			t.Errorf("changedBy(%q, %q) = %v, want %v", tt.uri, tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package mcp
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/types"
+)
+
+const (
+	// DefaultTaskTTL is how long a task is kept when the client does not ask
+	// for a TTL.
+	DefaultTaskTTL = time.Hour
+	// MaxTaskTTL is the longest a task is kept, whatever the client asks for.
+	MaxTaskTTL = 24 * time.Hour
+	// TaskPollInterval is the polling interval suggested to clients.
+	TaskPollInterval = time.Second
+)
+
+// relatedTaskMetaKey is the _meta key associating a message with a task.
+const relatedTaskMetaKey = "io.modelcontextprotocol/related-task"
+
+var (
+	// errTaskCancelled is the cause of the context of a task cancelled with
+	// tasks/cancel.
+	errTaskCancelled = errors.New("task cancelled by client")
+	// errTaskExpired is the cause of the context of a task whose TTL ran out.
+	errTaskExpired = errors.New("task expired")
+)
+
+// TaskStatus is the state of a task.
+type TaskStatus string
+
+const (
+	TaskWorking       TaskStatus = "working"
+	TaskInputRequired TaskStatus = "input_required"
+	TaskCompleted     TaskStatus = "completed"
+	TaskFailed        TaskStatus = "failed"
+	TaskCancelled     TaskStatus = "cancelled"
+)
+
+// terminal reports whether the task can no longer change status.
+func (st TaskStatus) terminal() bool {
+	return st == TaskCompleted || st == TaskFailed || st == TaskCancelled
+}
+
+// TaskSupport indicates whether a tool can be called as a task.
+type TaskSupport string
+
+const (
+	// TaskSupportForbidden tools cannot be called as tasks. It is the default.
+	TaskSupportForbidden TaskSupport = "forbidden"
+	// TaskSupportOptional tools can be called either way.
+	TaskSupportOptional TaskSupport = "optional"
+	// TaskSupportRequired tools must be called as tasks.
+	TaskSupportRequired TaskSupport = "required"
+)
+
+// ToolExecution describes how a tool can be executed.
+type ToolExecution struct {
+	TaskSupport TaskSupport `json:"taskSupport,omitempty"`
+}
+
+// TaskMetadata is sent with a request to run it as a task.
+type TaskMetadata struct {
+	// TTL is the requested retention in milliseconds from creation.
+	TTL *int64 `json:"ttl,omitempty"`
+}
+
+// Task describes a task.
+type Task struct {
+	TaskID        string     `json:"taskId"`
+	Status        TaskStatus `json:"status"`
+	StatusMessage *string    `json:"statusMessage,omitempty"`
+	// CreatedAt and LastUpdatedAt are ISO 8601 timestamps.
+	CreatedAt     string `json:"createdAt"`
+	LastUpdatedAt string `json:"lastUpdatedAt"`
+	// TTL is the retention in milliseconds from creation, null for unlimited.
+	TTL *int64 `json:"ttl"`
+	// PollInterval is the suggested polling interval in milliseconds.
+	PollInterval *int64 `json:"pollInterval,omitempty"`
+}
+
+// CreateTaskResult is the response to a request run as a task.
+type CreateTaskResult struct {
+	Task Task `json:"task"`
+}
+
+// TaskParams contains parameters for the tasks/get, tasks/result and
+// tasks/cancel requests.
+type TaskParams struct {
+	TaskID string `json:"taskId"`
+}
+
+// ListTasksResult is the server's response to a tasks/list request.
+type ListTasksResult struct {
+	Tasks []Task `json:"tasks"`
+	PaginatedResult
+}
+
+// ServerTasksCapability indicates that the server supports tasks.
+type ServerTasksCapability struct {
+	List     *Object                        `json:"list,omitempty"`
+	Cancel   *Object                        `json:"cancel,omitempty"`
+	Requests *ServerTasksRequestsCapability `json:"requests,omitempty"`
+}
+
+// ServerTasksRequestsCapability lists the requests the server can run as
+// tasks.
+type ServerTasksRequestsCapability struct {
+	Tools *struct {
+		Call *Object `json:"call,omitempty"`
+	} `json:"tools,omitempty"`
+}
+
+// task is a tool call running in the background.
+type task struct {
+	sessionID string
+	// cancel cancels the context the tool runs with.
+	cancel context.CancelCauseFunc
+	// notify sends notifications to the session that created the task.
+	notify func(method string, params json.RawMessage)
+
+	mu   sync.Mutex
+	info Task
+	// result and err hold the outcome of the tool call once the task is
+	// terminal.
+	result *CallToolResult
+	err    *jsonrpc.Error
+	// done is closed when the task becomes terminal.
+	done chan struct{}
+}
+
+// snapshot returns a copy of the task's description.
+func (t *task) snapshot() Task {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.info
+}
+
+// finish moves the task to a terminal status and sends a status
+// notification. A task that is already terminal is left as is. It reports
+// whether the status changed.
+func (t *task) finish(status TaskStatus, message string, result *CallToolResult, err *jsonrpc.Error) bool {
+	t.mu.Lock()
+	if t.info.Status.terminal() {
+		t.mu.Unlock()
+		return false
+	}
+
+	t.info.Status = status
+	t.info.LastUpdatedAt = timestamp(time.Now())
+	if message != "" {
+		t.info.StatusMessage = &message
+	}
+	t.result = result
+	t.err = err
+	close(t.done)
+	info := t.info
+	t.mu.Unlock()
+
+	t.notify("notifications/tasks/status", types.NewRawJSON(info))
+	return true
+}
+
+// taskStore holds the tasks of all sessions in memory. Tasks are dropped when
+// their TTL runs out or their session ends.
+type taskStore struct {
+	mu    sync.Mutex
+	tasks map[string]*task
+}
+
+func newTaskStore() *taskStore {
+	return &taskStore{
+		tasks: make(map[string]*task),
+	}
+}
+
+// get returns the task with the given ID if it belongs to the session.
+func (ts *taskStore) get(sessionID, id string) (*task, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	t, ok := ts.tasks[id]
+	if !ok || t.sessionID != sessionID {
+		return nil, false
+	}
+	return t, true
+}
+
+// list returns the tasks of the session, oldest first.
+func (ts *taskStore) list(sessionID string) []Task {
+	ts.mu.Lock()
+	var tasks []Task
+	for _, t := range ts.tasks {
+		if t.sessionID == sessionID {
+			tasks = append(tasks, t.snapshot())
+		}
+	}
+	ts.mu.Unlock()
+
+	// Timestamps have a fixed width, so they compare as strings.
+	slices.SortFunc(tasks, func(a, b Task) int {
+		return cmp.Or(cmp.Compare(a.CreatedAt, b.CreatedAt), cmp.Compare(a.TaskID, b.TaskID))
+	})
+	return tasks
+}
+
+// add stores t and drops it once ttl has passed.
+func (ts *taskStore) add(t *task, ttl time.Duration) {
+	ts.mu.Lock()
+	ts.tasks[t.info.TaskID] = t
+	ts.mu.Unlock()
+
+	time.AfterFunc(ttl, func() {
+		ts.remove(t.info.TaskID, errTaskExpired)
+	})
+}
+
+// remove drops the task with the given ID, cancelling it if it is still
+// running.
+func (ts *taskStore) remove(id string, cause error) {
+	ts.mu.Lock()
+	t, ok := ts.tasks[id]
+	delete(ts.tasks, id)
+	ts.mu.Unlock()
+
+	if ok {
+		t.cancel(cause)
+	}
+}
+
+// removeSession drops the tasks of a session that ended.
+func (ts *taskStore) removeSession(sessionID string) {
+	ts.mu.Lock()
+	var ids []string
+	for id, t := range ts.tasks {
+		if t.sessionID == sessionID {
+			ids = append(ids, id)
+		}
+	}
+	ts.mu.Unlock()
+
+	for _, id := range ids {
+		ts.remove(id, jsonrpc.ErrSessionClosed)
+	}
+}
+
+// timestamp formats t as an ISO 8601 timestamp of fixed width.
+func timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// taskTTL returns the retention of a task created with the given metadata.
+func taskTTL(metadata *TaskMetadata) time.Duration {
+	if metadata.TTL == nil || *metadata.TTL <= 0 {
+		return DefaultTaskTTL
+	}
+
+	return min(time.Duration(*metadata.TTL)*time.Millisecond, MaxTaskTTL)
+}
+
+// startToolTask runs the tool in the background and returns the created task.
+// The task outlives the request; it stops when it is cancelled, its TTL runs
+// out or the session ends.
+func (s *Server) startToolTask(ctx context.Context, tool *registeredTool, params CallToolParams) (json.RawMessage, *jsonrpc.Error) {
+	sess := s.session(ctx)
+	transportSession := jsonrpc.SessionFromContext(ctx)
+	if transportSession == nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Tasks require a session",
+		}
+	}
+
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+
+	ttl := taskTTL(params.Task)
+	now := timestamp(time.Now())
+	taskCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+
+	t := &task{
+		sessionID: transportSession.ID(),
+		cancel:    cancel,
+		notify: func(method string, params json.RawMessage) {
+			err := transportSession.Notify(context.Background(), method, params)
+			if err != nil {
+				log.Printf("Failed to send task notification: %v", err)
+			}
+		},
+		info: Task{
+			TaskID:        id,
+			Status:        TaskWorking,
+			CreatedAt:     now,
+			LastUpdatedAt: now,
+			TTL:           types.Ptr(ttl.Milliseconds()),
+			PollInterval:  types.Ptr(TaskPollInterval.Milliseconds()),
+		},
+		done: make(chan struct{}),
+	}
+	s.tasks.add(t, ttl)
+
+	go func() {
+		defer cancel(nil)
+
+		result, err := s.executeTool(taskCtx, tool, params)
+		switch {
+		case taskCtx.Err() != nil:
+			t.finish(TaskCancelled, context.Cause(taskCtx).Error(), nil, &jsonrpc.Error{
+				Code:    jsonrpc.CodeInternalError,
+				Message: "Task cancelled",
+			})
+		case err != nil:
+			t.finish(TaskFailed, err.Message, nil, err)
+		case result.IsError != nil && *result.IsError:
+			t.finish(TaskFailed, "", result, nil)
+		default:
+			t.finish(TaskCompleted, "", result, nil)
+		}
+	}()
+
+	sess.log(ctx, LevelDebug, "tasks", "Started task "+id+" for tool "+params.Name)
+	return types.NewRawJSON(CreateTaskResult{Task: t.snapshot()}), nil
+}
+
+// sessionID returns the ID of the transport session of ctx. Tasks are only
+// visible to the session that created them.
+func sessionID(ctx context.Context) string {
+	transportSession := jsonrpc.SessionFromContext(ctx)
+	if transportSession == nil {
+		return ""
+	}
+
+	return transportSession.ID()
+}
+
+// task returns the task named in the request params if it belongs to the
+// session of ctx.
+func (s *Server) task(ctx context.Context, p json.RawMessage) (*task, *jsonrpc.Error) {
+	var params TaskParams
+	err := json.Unmarshal(p, &params)
+	if err != nil || params.TaskID == "" {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Invalid params",
+		}
+	}
+
+	t, ok := s.tasks.get(sessionID(ctx), params.TaskID)
+	if !ok {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Failed to retrieve task: Task not found",
+		}
+	}
+	return t, nil
+}
+
+// GetTask is called when the client sends the "tasks/get" request. It returns
+// the current state of the task.
+func (s *Server) GetTask(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	t, err := s.task(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewRawJSON(t.snapshot()), nil
+}
+
+// ListTasks is called when the client sends the "tasks/list" request. It
+// returns a page of the tasks of the session, oldest first.
+func (s *Server) ListTasks(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	tasks, page, err := paginate(s.tasks.list(sessionID(ctx)), p, s.pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if tasks == nil {
+		tasks = []Task{}
+	}
+
+	return types.NewRawJSON(ListTasksResult{
+		Tasks:           tasks,
+		PaginatedResult: page,
+	}), nil
+}
+
+// TaskResult is called when the client sends the "tasks/result" request. It
+// waits for the task to finish and returns the result of the tool call, or
+// the error it failed with.
+func (s *Server) TaskResult(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	t, err := s.task(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Request cancelled",
+		}
+	}
+
+	t.mu.Lock()
+	result, resultErr := t.result, t.err
+	id := t.info.TaskID
+	t.mu.Unlock()
+
+	if resultErr != nil {
+		return nil, resultErr
+	}
+
+	// Copy the result, so concurrent requests don't share the _meta map.
+	withMeta := *result
+	withMeta.Meta = map[string]any{
+		relatedTaskMetaKey: map[string]string{"taskId": id},
+	}
+
+	resultBytes, marshalErr := json.Marshal(withMeta)
+	if marshalErr != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal tool result",
+		}
+	}
+
+	return resultBytes, nil
+}
+
+// CancelTask is called when the client sends the "tasks/cancel" request. It
+// stops the task and returns its state.
+func (s *Server) CancelTask(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	t, err := s.task(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled := t.finish(TaskCancelled, "Cancelled by client", nil, &jsonrpc.Error{
+		Code:    jsonrpc.CodeInternalError,
+		Message: "Task cancelled",
+	})
+	if !cancelled {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInvalidParams,
+			Message: "Cannot cancel task: already in terminal status",
+		}
+	}
+	t.cancel(errTaskCancelled)
+
+	return types.NewRawJSON(t.snapshot()), nil
+}
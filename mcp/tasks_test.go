@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+// taskTool can be called as a task. It blocks until its context is cancelled
+// if the "block" argument is set.
+type taskTool struct{}
+
+func (taskTool) Definition() Tool {
+	return Tool{
+		Name:      "task",
+		Execution: &ToolExecution{TaskSupport: TaskSupportOptional},
+	}
+}
+
+func (taskTool) Execute(ctx context.Context, params json.RawMessage) (*CallToolResult, *jsonrpc.Error) {
+	if strings.Contains(string(params), "block") {
+		<-ctx.Done()
+		return nil, &jsonrpc.Error{Code: jsonrpc.CodeInternalError, Message: "cancelled"}
+	}
+	return NewTextResult("done"), nil
+}
+
+func TestTaskCompletes(t *testing.T) {
+	c := newTestClient(t)
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"task","arguments":{},"task":{"ttl":60000}}}`)
+	var created CreateTaskResult
+	c.result("1", &created)
+	if created.Task.Status != TaskWorking {
+		t.Errorf("Status = %q, want %q", created.Task.Status, TaskWorking)
+	}
+	if created.Task.TTL == nil || *created.Task.TTL != 60000 {
+		t.Errorf("TTL = %v, want 60000", created.Task.TTL)
+	}
+	id := created.Task.TaskID
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"tasks/result","params":{"taskId":"` + id + `"}}`)
+	var result CallToolResult
+	c.result("2", &result)
+	if len(result.Content) != 1 || result.Content[0] != NewTextContent("done") {
+		t.Errorf("Content = %+v, want done", result.Content)
+	}
+	related, _ := result.Meta[relatedTaskMetaKey].(map[string]any)
+	if related["taskId"] != id {
+		t.Errorf("related task = %v, want %s", result.Meta[relatedTaskMetaKey], id)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"tasks/get","params":{"taskId":"` + id + `"}}`)
+	var task Task
+	c.result("3", &task)
+	if task.Status != TaskCompleted {
+		t.Errorf("Status = %q, want %q", task.Status, TaskCompleted)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":4,"method":"tasks/list"}`)
+	var list ListTasksResult
+	c.result("4", &list)
+	if len(list.Tasks) != 1 || list.Tasks[0].TaskID != id {
+		t.Errorf("Tasks = %+v, want the task", list.Tasks)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":5,"method":"tasks/cancel","params":{"taskId":"` + id + `"}}`)
+	resp := c.response("5")
+	if resp.Error == nil || resp.Error.Code != jsonrpc.CodeInvalidParams {
+		t.Errorf("cancel of completed task: Error = %v, want code %d", resp.Error, jsonrpc.CodeInvalidParams)
+	}
+}
+
+func TestTaskCancel(t *testing.T) {
+	c := newTestClient(t)
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"task","arguments":{"block":true},"task":{}}}`)
+	var created CreateTaskResult
+	c.result("1", &created)
+	id := created.Task.TaskID
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"tasks/cancel","params":{"taskId":"` + id + `"}}`)
+	var task Task
+	c.result("2", &task)
+	if task.Status != TaskCancelled {
+		t.Errorf("Status = %q, want %q", task.Status, TaskCancelled)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"tasks/result","params":{"taskId":"` + id + `"}}`)
+	if resp := c.response("3"); resp.Error == nil {
+		t.Error("result of cancelled task: expected error, got nil")
+	}
+}
+
+func TestTaskNotFound(t *testing.T) {
+	c := newTestClient(t)
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"tasks/get","params":{"taskId":"nope"}}`)
+	resp := c.response("1")
+	if resp.Error == nil || resp.Error.Code != jsonrpc.CodeInvalidParams {
+		t.Errorf("Error = %v, want code %d", resp.Error, jsonrpc.CodeInvalidParams)
+	}
+}
+
+func TestTaskForbidden(t *testing.T) {
+	c := newTestClient(t)
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"calculator","arguments":{"expression":"1+1"},"task":{}}}`)
+	resp := c.response("1")
+	if resp.Error == nil || resp.Error.Code != jsonrpc.CodeMethodNotFound {
+		t.Errorf("Error = %v, want code %d", resp.Error, jsonrpc.CodeMethodNotFound)
+	}
+}
+
+func TestTaskIgnoredBeforeTasksVersion(t *testing.T) {
+	resps := serve(t,
+		strings.Replace(initializeRequest, ProtocolVersion, "2025-06-18", 1),
+		initializedNotification,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"calculator","arguments":{"expression":"1+1"},"task":{}}}`,
+	)
+
+	resp := resps["1"]
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var result CallToolResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(result.Content) != 1 || result.Content[0] != NewTextContent(`{"result":2}`) {
+		t.Errorf("Content = %+v, want {\"result\":2}", result.Content)
+	}
+}
@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aphilas/pgmcp/pkg/postgres"
+	"github.com/aphilas/pgmcp/pkg/types"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// QueryMaxRows is the maximum number of rows the query tool returns.
+const QueryMaxRows = 1000
+
+// QuerySlowThreshold is the duration above which a query is logged as slow.
+const QuerySlowThreshold = time.Second
+
+type QueryParams struct {
+	SQL string `json:"sql" jsonschema:"The SQL query to run. The query runs inside a READ ONLY transaction."`
+}
+
+func NewQuery(db *pgxpool.Pool) (*TypedTool[QueryParams, postgres.Result], error) {
+	tool, err := NewTypedTool(
+		"query",
+		fmt.Sprintf("Run a read-only SQL query against the Postgres database. Returns at most %d rows.", QueryMaxRows),
+		func(ctx context.Context, p QueryParams) (postgres.Result, error) {
+			return query(ctx, db, p)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tool.Tool.Title = types.Ptr("Query Postgres")
+	// Long running queries can be started as tasks and collected later.
+	tool.Tool.Execution = &ToolExecution{TaskSupport: TaskSupportOptional}
+	return tool, nil
+}
+
+func query(ctx context.Context, db *pgxpool.Pool, p QueryParams) (postgres.Result, error) {
+	progress := ProgressFromContext(ctx)
+	reportRows := func(rows int) {
+		progress.Report(ctx, float64(rows), 0, fmt.Sprintf("Fetched %d rows", rows))
+	}
+
+	start := time.Now()
+	res, err := postgres.QueryReadOnly(ctx, db, p.SQL, QueryMaxRows, reportRows)
+	duration := time.Since(start)
+	if duration > QuerySlowThreshold {
+		Log(ctx, LevelWarning, "query", map[string]any{
+			"message":  "Slow query",
+			"sql":      p.SQL,
+			"duration": duration.String(),
+		})
+	}
+	if err != nil {
+		// Errors from Postgres (syntax errors, permission errors, writes in a
+		// read-only transaction) are reported to the model so it can correct
+		// the query.
+		Log(ctx, LevelError, "query", map[string]any{
+			"message": "Query failed",
+			"sql":     p.SQL,
+			"error":   err.Error(),
+		})
+		return postgres.Result{}, fmt.Errorf("Error running query: %s", err.Error())
+	}
+
+	return *res, nil
+}
@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// unreachablePool returns a pool whose connections fail. pgxpool connects
+// lazily, so creating it succeeds.
+func unreachablePool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	db, err := pgxpool.New(context.Background(), "postgres://pgmcp@127.0.0.1:1/pgmcp?connect_timeout=1")
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestQueryErrors(t *testing.T) {
+	query, err := NewQuery(unreachablePool(t))
+	if err != nil {
+		t.Fatalf("NewQuery: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		params   string
+		wantText string
+	}{
+		{"invalid JSON", `{"sql":`, "Error parsing parameters"},
+		{"missing sql", `{}`, "Invalid parameters"},
+		{"wrong type", `{"sql":1}`, "Invalid parameters"},
+		{"connection error", `{"sql":"SELECT 1"}`, "Error running query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, rpcErr := query.Execute(context.Background(), json.RawMessage(tt.params))
+			if rpcErr != nil {
+				t.Fatalf("unexpected protocol error: %v", rpcErr)
+			}
+			if result.IsError == nil || !*result.IsError {
+				t.Fatalf("IsError = false, want true: %+v", result.Content)
+			}
+			text, ok := result.Content[0].(TextContent)
+			if !ok || !strings.Contains(text.Text, tt.wantText) {
+				t.Errorf("Content[0] = %+v, want text containing %q", result.Content[0], tt.wantText)
+			}
+		})
+	}
+}
+
+func TestQueryCancelled(t *testing.T) {
+	query, err := NewQuery(unreachablePool(t))
+	if err != nil {
+		t.Fatalf("NewQuery: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, rpcErr := query.Execute(ctx, json.RawMessage(`{"sql":"SELECT 1"}`))
+	if rpcErr == nil || rpcErr.Code != jsonrpc.CodeInternalError {
+		t.Errorf("Execute with cancelled context: error = %v, want an internal error", rpcErr)
+	}
+}
@@ -0,0 +1,122 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/aphilas/pgmcp/pkg/types"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TypedTool is a tool implemented by a function taking arguments of type In
+// and returning a result of type Out. The input and output schemas are derived
+// from the types.
+type TypedTool[In, Out any] struct {
+	Tool        Tool
+	InputSchema *jsonschema.Resolved
+
+	handler func(context.Context, In) (Out, error)
+}
+
+// NewTypedTool creates a tool calling handler. In and Out must be struct (or
+// map) types; their fields are documented with jsonschema struct tags.
+//
+// Arguments are validated against the input schema before handler is called.
+// The result is returned as structured content, which the server validates
+// against the output schema, with its JSON encoding as text content for
+// clients that don't read structured content. An error returned by handler is
+// reported to the model as a tool error, unless it is a *jsonrpc.Error or the
+// request was cancelled.
+func NewTypedTool[In, Out any](name, description string, handler func(context.Context, In) (Out, error)) (*TypedTool[In, Out], error) {
+	inputSchema, err := jsonschema.For[In](nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating input schema: %w", err)
+	}
+
+	inputSchemaResolved, err := inputSchema.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("resolving input schema: %w", err)
+	}
+
+	outputSchema, err := jsonschema.For[Out](nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating output schema: %w", err)
+	}
+	if outputSchema.Type != "object" {
+		return nil, fmt.Errorf("output type must be an object, got %q", outputSchema.Type)
+	}
+
+	return &TypedTool[In, Out]{
+		Tool: Tool{
+			Name:         name,
+			Description:  types.Ptr(description),
+			InputSchema:  inputSchema,
+			OutputSchema: outputSchema,
+		},
+		InputSchema: inputSchemaResolved,
+		handler:     handler,
+	}, nil
+}
+
+func (t *TypedTool[In, Out]) Definition() Tool {
+	return t.Tool
+}
+
+func (t *TypedTool[In, Out]) Execute(ctx context.Context, params json.RawMessage) (*CallToolResult, *jsonrpc.Error) {
+	if len(params) == 0 {
+		params = json.RawMessage(`{}`)
+	}
+
+	// Validate does NOT take a struct: See:
+	// https://github.com/google/jsonschema-go/issues/23
+	var object map[string]any
+	err := json.Unmarshal(params, &object)
+	if err != nil {
+		return NewErrorTextResult(fmt.Sprintf("Error parsing parameters: %s", err.Error())), nil
+	}
+
+	err = t.InputSchema.Validate(object)
+	if err != nil {
+		return NewErrorTextResult(fmt.Sprintf("Invalid parameters: %s", err.Error())), nil
+	}
+
+	var in In
+	err = json.Unmarshal(params, &in)
+	if err != nil {
+		return NewErrorTextResult(fmt.Sprintf("Error parsing parameters: %s", err.Error())), nil
+	}
+
+	out, err := t.handler(ctx, in)
+	if err != nil {
+		// The error of a cancelled call, e.g. a cancelled query, isn't the
+		// model's to fix. A call that finished before the cancellation keeps
+		// its result.
+		if ctx.Err() != nil {
+			return nil, &jsonrpc.Error{
+				Code:    jsonrpc.CodeInternalError,
+				Message: "Tool call cancelled",
+			}
+		}
+
+		var rpcErr *jsonrpc.Error
+		if errors.As(err, &rpcErr) {
+			return nil, rpcErr
+		}
+		return NewErrorTextResult(err.Error()), nil
+	}
+
+	structured, err := json.Marshal(out)
+	if err != nil {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Failed to marshal tool result",
+		}
+	}
+
+	result := NewTextResult(string(structured))
+	result.StructuredContent = structured
+	return result, nil
+}
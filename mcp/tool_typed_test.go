@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+)
+
+type greetParams struct {
+	Name string `json:"name"`
+}
+
+type greetResult struct {
+	Greeting string `json:"greeting"`
+}
+
+func greet(ctx context.Context, p greetParams) (greetResult, error) {
+	if p.Name == "" {
+		return greetResult{}, errors.New("name is empty")
+	}
+	if p.Name == "internal" {
+		return greetResult{}, &jsonrpc.Error{Code: jsonrpc.CodeInternalError, Message: "internal"}
+	}
+	return greetResult{Greeting: "Hello, " + p.Name}, nil
+}
+
+func TestTypedTool(t *testing.T) {
+	tool, err := NewTypedTool("greet", "Greets someone.", greet)
+	if err != nil {
+		t.Fatalf("NewTypedTool: %v", err)
+	}
+	if tool.Tool.InputSchema == nil || tool.Tool.OutputSchema == nil {
+		t.Fatal("schemas not derived")
+	}
+
+	tests := []struct {
+		name       string
+		params     string
+		wantIsErr  bool
+		wantRPCErr bool
+		want       string
+	}{
+		{"ok", `{"name":"Ada"}`, false, false, `{"greeting":"Hello, Ada"}`},
+		{"missing argument", `{}`, true, false, ""},
+		{"wrong type", `{"name":1}`, true, false, ""},
+		{"handler error", `{"name":""}`, true, false, ""},
+		{"protocol error", `{"name":"internal"}`, false, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, rpcErr := tool.Execute(context.Background(), json.RawMessage(tt.params))
+			if tt.wantRPCErr {
+				if rpcErr == nil {
+					t.Fatal("expected protocol error, got nil")
+				}
+				return
+			}
+			if rpcErr != nil {
+				t.Fatalf("unexpected protocol error: %v", rpcErr)
+			}
+
+			isErr := result.IsError != nil && *result.IsError
+			if isErr != tt.wantIsErr {
+				t.Fatalf("IsError = %v, want %v: %+v", isErr, tt.wantIsErr, result.Content)
+			}
+			if tt.wantIsErr {
+				return
+			}
+			if string(result.StructuredContent) != tt.want {
+				t.Errorf("StructuredContent = %s, want %s", result.StructuredContent, tt.want)
+			}
+			if result.Content[0] != NewTextContent(tt.want) {
+				t.Errorf("Content[0] = %+v, want text %s", result.Content[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestTypedToolRequiresObjectOutput(t *testing.T) {
+	_, err := NewTypedTool("count", "Counts.", func(ctx context.Context, p greetParams) (int, error) {
+		return 0, nil
+	})
+	if err == nil {
+		t.Error("NewTypedTool with int output: expected error, got nil")
+	}
+}
+
+func TestTypedToolCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tool, err := NewTypedTool("greet", "Greets someone.", greet)
+	if err != nil {
+		t.Fatalf("NewTypedTool: %v", err)
+	}
+
+	// A call that finished keeps its result.
+	result, rpcErr := tool.Execute(ctx, json.RawMessage(`{"name":"Ada"}`))
+	if rpcErr != nil || result.IsError != nil {
+		t.Errorf("Execute = %+v, %v, want the greeting", result, rpcErr)
+	}
+
+	_, rpcErr = tool.Execute(ctx, json.RawMessage(`{"name":""}`))
+	if rpcErr == nil || rpcErr.Message != "Tool call cancelled" {
+		t.Errorf("Execute failing after cancellation: error = %v, want Tool call cancelled", rpcErr)
+	}
+}
@@ -1,7 +1,10 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 
 	"github.com/aphilas/pgmcp/pkg/jsonrpc"
@@ -10,16 +13,23 @@ import (
 
 type Tooler interface {
 	Definition() Tool
-	Execute(params json.RawMessage) (*CallToolResult, *jsonrpc.Error)
+	// Execute runs the tool. ctx is cancelled if the client cancels the
+	// request. Long running tools report their progress to the
+	// ProgressReporter returned by ProgressFromContext.
+	Execute(ctx context.Context, params json.RawMessage) (*CallToolResult, *jsonrpc.Error)
 }
 
 // Tool defines a tool the client can call. Omitted: icons, annotations,
-// execution, _meta.
+// _meta.
 type Tool struct {
 	Name        string  `json:"name"`
 	Title       *string `json:"title,omitempty"`
 	Description *string `json:"description,omitempty"`
 
+	// Execution describes whether the tool can be called as a task. Tools
+	// without it cannot.
+	Execution *ToolExecution `json:"execution,omitempty"`
+
 	// InputSchema and OutputSchema are JSON Schema objects defining the
 	// expected input parameters and output of the tool, respectively. Type is
 	// always "object".
@@ -27,19 +37,24 @@ type Tool struct {
 	OutputSchema *jsonschema.Schema `json:"outputSchema,omitempty"`
 }
 
-// ListToolsParams contains parameters for a tools/list request. We do NOT
-// support pagination. Omitted: cursor.
-type ListToolsParams struct{}
+// ListToolsParams contains parameters for a tools/list request.
+type ListToolsParams struct {
+	PaginatedParams
+}
 
 // ListToolsResult is the server's response to a tools/list request.
-// Omitted: nextCursor.
 type ListToolsResult struct {
 	Tools []Tool `json:"tools"`
+	PaginatedResult
 }
 
 // CallToolParams contains parameters for a tools/call request.
-// Omitted: task.
 type CallToolParams struct {
+	Meta *RequestMeta `json:"_meta,omitempty"`
+
+	// Task is set if the client wants to run the tool as a task.
+	Task *TaskMetadata `json:"task,omitempty"`
+
 	// The name of the tool.
 	Name string `json:"name"`
 
@@ -48,16 +63,10 @@ type CallToolParams struct {
 	Arguments json.RawMessage `json:"arguments,omitempty"`
 }
 
-// TextContent represents text content returned by a tool call.
-type TextContent struct {
-	Type string `json:"type"` // always "text"
-	Text string `json:"text"`
-}
-
 // CallToolResult is the server's response to a tools/call request.
 type CallToolResult struct {
 	// Content represents the unstructured result of the tool call.
-	Content []TextContent `json:"content"`
+	Content []Content `json:"content"`
 	// StructuredContent is a JSON object containing the structured content
 	// returned by the tool. Type: map[string]any.
 	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
@@ -65,40 +74,90 @@ type CallToolResult struct {
 	// originate from the tool should be reported inside the result object.
 	// Other exceptional conditions should MCP protocol-level errors.
 	IsError *bool `json:"isError,omitempty"`
+
+	Meta map[string]any `json:"_meta,omitempty"`
 }
 
 func NewTextResult(text string) *CallToolResult {
 	return &CallToolResult{
-		Content: []TextContent{
-			TextContent{
-				Type: "text",
-				Text: text,
-			},
-		},
+		Content: []Content{NewTextContent(text)},
 	}
 }
 
 func NewErrorTextResult(text string) *CallToolResult {
 	isError := true
 	return &CallToolResult{
-		Content: []TextContent{
-			{
-				Type: "text",
-				Text: text,
-			},
-		},
+		Content: []Content{NewTextContent(text)},
 		IsError: &isError,
 	}
 }
 
+// registeredTool is a tool offered by the server.
+type registeredTool struct {
+	Tooler
+	// outputSchema is the resolved output schema of the tool, or nil if the
+	// tool has none.
+	outputSchema *jsonschema.Resolved
+}
+
+// AddTool registers a tool, replacing any tool with the same name, and tells
+// connected clients that the list of tools changed. It is safe to call while
+// the server is running.
+func (s *Server) AddTool(tool Tooler) error {
+	definition := tool.Definition()
+
+	registered := &registeredTool{Tooler: tool}
+	if definition.OutputSchema != nil {
+		resolved, err := definition.OutputSchema.Resolve(nil)
+		if err != nil {
+			return fmt.Errorf("resolving output schema of tool %s: %w", definition.Name, err)
+		}
+		registered.outputSchema = resolved
+	}
+
+	s.tools.add(definition.Name, registered)
+	s.notifyAll("notifications/tools/list_changed", nil)
+	return nil
+}
+
+// RemoveTool unregisters the tool with the given name and tells connected
+// clients that the list of tools changed. It reports whether there was such a
+// tool.
+func (s *Server) RemoveTool(name string) bool {
+	if !s.tools.remove(name) {
+		return false
+	}
+
+	s.notifyAll("notifications/tools/list_changed", nil)
+	return true
+}
+
 // ListTools is called when the client sends the "tools/list" request. It
-// returns a list of tools the server supports.
-func (s Server) ListTools(p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+// returns a page of the tools the server supports, in registration order.
+func (s *Server) ListTools(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+	page, cursor, pageErr := paginate(s.tools.list(), p, s.pageSize)
+	if pageErr != nil {
+		return nil, pageErr
+	}
+
+	sess := s.session(ctx)
+	structured := sess.Supports(versionStructuredContent)
+	tasks := sess.Supports(versionTasks)
+
 	tools := ListToolsResult{
-		Tools: make([]Tool, 0, len(s.Tools)),
+		Tools:           make([]Tool, 0, len(page)),
+		PaginatedResult: cursor,
 	}
-	for _, tool := range s.Tools {
-		tools.Tools = append(tools.Tools, tool.Definition())
+	for _, tool := range page {
+		definition := tool.Definition()
+		if !structured {
+			definition.Title = nil
+			definition.OutputSchema = nil
+		}
+		if !tasks {
+			definition.Execution = nil
+		}
+		tools.Tools = append(tools.Tools, definition)
 	}
 
 	resultBytes, err := json.Marshal(tools)
@@ -115,7 +174,7 @@ func (s Server) ListTools(p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
 // CallTool is called when the client sends the "tools/call" request. It
 // executes the specified tool with the provided arguments and returns the
 // result.
-func (s Server) CallTool(p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
+func (s *Server) CallTool(ctx context.Context, p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
 	var params CallToolParams
 	err := json.Unmarshal(p, &params)
 	if err != nil {
@@ -126,7 +185,7 @@ func (s Server) CallTool(p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
 		}
 	}
 
-	tool, ok := s.Tools[params.Name]
+	tool, ok := s.tools.get(params.Name)
 	if !ok {
 		return nil, &jsonrpc.Error{
 			Code:    jsonrpc.CodeInvalidParams,
@@ -134,11 +193,36 @@ func (s Server) CallTool(p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
 		}
 	}
 
-	result, toolErr := tool.Execute(params.Arguments)
+	// Clients that predate tasks run every tool synchronously.
+	asTask := params.Task != nil && s.session(ctx).Supports(versionTasks)
+
+	taskSupport := TaskSupportForbidden
+	if execution := tool.Definition().Execution; execution != nil && execution.TaskSupport != "" {
+		taskSupport = execution.TaskSupport
+	}
+	if (asTask && taskSupport == TaskSupportForbidden) || (!asTask && taskSupport == TaskSupportRequired) {
+		return nil, &jsonrpc.Error{
+			Code:    jsonrpc.CodeMethodNotFound,
+			Message: "Tool task support is " + string(taskSupport),
+		}
+	}
+
+	if asTask {
+		return s.startToolTask(ctx, tool, params)
+	}
+
+	result, toolErr := s.executeTool(ctx, tool, params)
 	if toolErr != nil {
 		return nil, toolErr
 	}
 
+	// Clients that predate structured content only read the text content, and
+	// don't know resource links.
+	if !s.session(ctx).Supports(versionStructuredContent) {
+		result.StructuredContent = nil
+		result.Content = linksAsText(result.Content)
+	}
+
 	resultBytes, err := json.Marshal(result)
 	if err != nil {
 		return nil, &jsonrpc.Error{
@@ -149,3 +233,59 @@ func (s Server) CallTool(p json.RawMessage) (json.RawMessage, *jsonrpc.Error) {
 
 	return resultBytes, nil
 }
+
+// executeTool runs the tool with the arguments of the call and checks the
+// result against the tool's output schema.
+func (s *Server) executeTool(ctx context.Context, tool *registeredTool, params CallToolParams) (*CallToolResult, *jsonrpc.Error) {
+	if params.Meta != nil && params.Meta.ProgressToken != nil {
+		ctx = withProgress(ctx, params.Meta.ProgressToken)
+	}
+
+	result, err := tool.Execute(ctx, params.Arguments)
+	if err != nil {
+		Log(ctx, LevelError, params.Name, err.Message)
+		return nil, err
+	}
+
+	err = s.validateResult(ctx, tool, params.Name, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// validateResult checks that a successful result has structured content
+// matching the tool's output schema. In dev mode a mismatch fails the call
+// with an internal error; otherwise it is logged and the result is returned
+// as is.
+func (s *Server) validateResult(ctx context.Context, tool *registeredTool, name string, result *CallToolResult) *jsonrpc.Error {
+	if tool.outputSchema == nil || (result.IsError != nil && *result.IsError) {
+		return nil
+	}
+
+	// Validate does NOT take a struct: See:
+	// https://github.com/google/jsonschema-go/issues/23
+	err := errors.New("no structured content")
+	if result.StructuredContent != nil {
+		var object map[string]any
+		err = json.Unmarshal(result.StructuredContent, &object)
+		if err == nil {
+			err = tool.outputSchema.Validate(object)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	if s.dev {
+		return &jsonrpc.Error{
+			Code:    jsonrpc.CodeInternalError,
+			Message: "Tool result does not match its output schema",
+			Data:    jsonrpc.JSONRawMessage(err.Error()),
+		}
+	}
+
+	Log(ctx, LevelError, name, fmt.Sprintf("Result does not match the output schema: %v", err))
+	return nil
+}
@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aphilas/pgmcp/pkg/jsonrpc"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// notification reads messages until a notification, skipping responses.
+func (c *testClient) notification() jsonrpc.Notification {
+	c.t.Helper()
+	for {
+		var msg jsonrpc.Request
+		if err := c.dec.Decode(&msg); err != nil {
+			c.t.Fatalf("Decode: %v", err)
+		}
+		if msg.ID == nil {
+			return jsonrpc.Notification{JSONRPC: msg.JSONRPC, Method: msg.Method, Params: msg.Params}
+		}
+	}
+}
+
+func TestToolListChanged(t *testing.T) {
+	c := newTestClient(t)
+
+	c.server.AddTool(countingTool{})
+	if n := c.notification(); n.Method != "notifications/tools/list_changed" {
+		t.Errorf("Method = %q, want notifications/tools/list_changed", n.Method)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	var result ListToolsResult
+	c.result("2", &result)
+	found := false
+	for _, tool := range result.Tools {
+		found = found || tool.Name == "count"
+	}
+	if !found {
+		t.Errorf("tools/list does not include the added tool: %+v", result.Tools)
+	}
+
+	if !c.server.RemoveTool("count") {
+		t.Fatal("RemoveTool(count) = false, want true")
+	}
+	if n := c.notification(); n.Method != "notifications/tools/list_changed" {
+		t.Errorf("Method = %q, want notifications/tools/list_changed", n.Method)
+	}
+	if c.server.RemoveTool("count") {
+		t.Error("second RemoveTool(count) = true, want false")
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"count"}}`)
+	if resp := c.response("3"); resp.Error == nil {
+		t.Error("call of removed tool: expected error, got nil")
+	}
+}
+
+// badResultTool declares a numeric result but returns a string.
+type badResultTool struct{}
+
+func (badResultTool) Definition() Tool {
+	return Tool{
+		Name: "bad",
+		OutputSchema: &jsonschema.Schema{
+			Type:       "object",
+			Properties: map[string]*jsonschema.Schema{"result": {Type: "number"}},
+			Required:   []string{"result"},
+		},
+	}
+}
+
+func (badResultTool) Execute(ctx context.Context, params json.RawMessage) (*CallToolResult, *jsonrpc.Error) {
+	result := NewTextResult("not a number")
+	result.StructuredContent = json.RawMessage(`{"result":"not a number"}`)
+	return result, nil
+}
+
+func TestOutputSchemaValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		dev     bool
+		wantErr bool
+	}{
+		{"dev", true, true},
+		{"prod", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resps := serveWith(t, Options{Dev: tt.dev}, func(s *Server) {
+				if err := s.AddTool(badResultTool{}); err != nil {
+					t.Fatalf("AddTool: %v", err)
+				}
+			},
+				initializeRequest,
+				initializedNotification,
+				`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bad"}}`,
+				`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"calculator","arguments":{"expression":"6*7"}}}`,
+			)
+
+			resp := resps["1"]
+			if got := resp.Error != nil; got != tt.wantErr {
+				t.Errorf("bad result: got error %v, want error = %v", resp.Error, tt.wantErr)
+			}
+			if tt.wantErr && resp.Error.Code != jsonrpc.CodeInternalError {
+				t.Errorf("Error.Code = %d, want %d", resp.Error.Code, jsonrpc.CodeInternalError)
+			}
+
+			// The calculator's result matches its output schema.
+			var result CallToolResult
+			if resps["2"].Error != nil {
+				t.Fatalf("calculator: unexpected error: %v", resps["2"].Error)
+			}
+			if err := json.Unmarshal(resps["2"].Result, &result); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if string(result.StructuredContent) != `{"result":42}` {
+				t.Errorf("StructuredContent = %s, want {\"result\":42}", result.StructuredContent)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCallTimeout is how long Session.Call waits for the client's response
+// when the context has no deadline.
+const DefaultCallTimeout = 60 * time.Second
+
+var (
+	// errCancelled is the cause of a request context cancelled by the
+	// client.
+	errCancelled = errors.New("request cancelled by client")
+
+	// ErrConnClosed is returned by Session.Call and Session.Notify once the
+	// connection to the client is closed.
+	ErrConnClosed = errors.New("jsonrpc: connection closed")
+
+	// ErrSessionClosed is the cause of the contexts of requests that were in
+	// flight when their session was closed. StdioServer.Serve returns it
+	// when the session is closed before the input is exhausted.
+	ErrSessionClosed = errors.New("jsonrpc: session closed")
+)
+
+// Session is the connection to a single client as seen by a request handler.
+// It lets the server send its own requests and notifications to the client.
+type Session interface {
+	// ID identifies the session for as long as it lives.
+	ID() string
+	// Call sends a request to the client and waits for its response. If ctx
+	// has no deadline, Call gives up after DefaultCallTimeout. An error
+	// response from the client is returned as an *Error.
+	Call(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error)
+	// Notify sends a notification to the client.
+	Notify(ctx context.Context, method string, params json.RawMessage) error
+	// Done returns a channel that is closed when the session ends: the stdio
+	// input is exhausted or the HTTP session is terminated.
+	Done() <-chan struct{}
+	// Close ends the session from the server side, e.g. when the client
+	// stopped responding. In-flight requests are cancelled with
+	// ErrSessionClosed.
+	Close()
+}
+
+// sendFunc writes a message to the client.
+type sendFunc func(ctx context.Context, msg any) error
+
+// conn holds the state of the connection to a single client. The stdio
+// transport has exactly one; the HTTP transport has one per session.
+type conn struct {
+	id string
+	// send writes server-initiated messages to the client.
+	send sendFunc
+	// terminate ends the session in the transport. It is called by Close.
+	terminate func()
+
+	mu sync.Mutex
+	// cancels holds the cancel functions of in-flight requests, keyed by
+	// request ID.
+	cancels map[string]context.CancelCauseFunc
+	// pending holds the channels Call waits on for responses, keyed by the ID
+	// of the outgoing request.
+	pending map[string]chan *Response
+	nextID  atomic.Int64
+
+	// hangup is closed once no more messages can arrive from the client, so
+	// pending and future calls fail instead of waiting for a response.
+	hangup     chan struct{}
+	hangupOnce sync.Once
+	// done is closed when the session has ended.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newConn(id string, send sendFunc, terminate func()) *conn {
+	return &conn{
+		id:        id,
+		send:      send,
+		terminate: terminate,
+		cancels:   make(map[string]context.CancelCauseFunc),
+		pending:   make(map[string]chan *Response),
+		hangup:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+type connContextKey struct{}
+type sessionContextKey struct{}
+
+// withConn returns a copy of ctx carrying c, which is also the session
+// returned by SessionFromContext.
+func withConn(ctx context.Context, c *conn) context.Context {
+	ctx = context.WithValue(ctx, connContextKey{}, c)
+	return withSession(ctx, c)
+}
+
+// withSession returns a copy of ctx carrying s.
+func withSession(ctx context.Context, s Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, s)
+}
+
+func connFromContext(ctx context.Context) *conn {
+	c, _ := ctx.Value(connContextKey{}).(*conn)
+	return c
+}
+
+// SessionFromContext returns the session of the request being handled, or nil
+// if ctx does not belong to a request.
+func SessionFromContext(ctx context.Context) Session {
+	s, _ := ctx.Value(sessionContextKey{}).(Session)
+	return s
+}
+
+// ID implements Session.
+func (c *conn) ID() string {
+	return c.id
+}
+
+// Done implements Session.
+func (c *conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close implements Session.
+func (c *conn) Close() {
+	c.terminate()
+}
+
+// Call implements Session.
+func (c *conn) Call(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+	return c.call(ctx, c.send, method, params)
+}
+
+// Notify implements Session.
+func (c *conn) Notify(ctx context.Context, method string, params json.RawMessage) error {
+	return c.notify(ctx, c.send, method, params)
+}
+
+// call sends a request with send and waits for the response to be delivered.
+func (c *conn) call(ctx context.Context, send sendFunc, method string, params json.RawMessage) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultCallTimeout)
+		defer cancel()
+	}
+
+	req, err := NewRequest(method, params, c.nextID.Add(1))
+	if err != nil {
+		return nil, err
+	}
+
+	key := string(req.ID)
+	respCh := make(chan *Response, 1)
+
+	c.mu.Lock()
+	select {
+	case <-c.hangup:
+		c.mu.Unlock()
+		return nil, ErrConnClosed
+	default:
+	}
+	c.pending[key] = respCh
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	err = send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-respCh:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		// Let the client know that the response is no longer needed.
+		cancelled := Notification{
+			JSONRPC: Version,
+			Method:  "notifications/cancelled",
+			Params: JSONRawMessage(map[string]any{
+				"requestId": req.ID,
+				"reason":    context.Cause(ctx).Error(),
+			}),
+		}
+		send(context.WithoutCancel(ctx), cancelled)
+		return nil, ctx.Err()
+	case <-c.hangup:
+		return nil, ErrConnClosed
+	}
+}
+
+// notify sends a notification with send.
+func (c *conn) notify(ctx context.Context, send sendFunc, method string, params json.RawMessage) error {
+	select {
+	case <-c.done:
+		return ErrConnClosed
+	default:
+	}
+
+	return send(ctx, Notification{
+		JSONRPC: Version,
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// deliver hands a response from the client to the Call waiting for it. It
+// reports whether a Call was waiting.
+func (c *conn) deliver(resp *Response) bool {
+	c.mu.Lock()
+	respCh, ok := c.pending[string(resp.ID)]
+	delete(c.pending, string(resp.ID))
+	c.mu.Unlock()
+
+	if ok {
+		respCh <- resp
+	}
+	return ok
+}
+
+// hangUp records that the client can no longer send messages. Pending and
+// future calls fail with ErrConnClosed.
+func (c *conn) hangUp() {
+	c.hangupOnce.Do(func() {
+		c.mu.Lock()
+		close(c.hangup)
+		c.mu.Unlock()
+	})
+}
+
+// close ends the session. Calls and notifications fail with ErrConnClosed.
+func (c *conn) close() {
+	c.hangUp()
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// track returns a context for the request with the given ID that is cancelled
+// when the client cancels the request. The returned function MUST be called
+// once the request has been handled.
+func (c *conn) track(ctx context.Context, id json.RawMessage) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := string(id)
+
+	c.mu.Lock()
+	c.cancels[key] = cancel
+	c.mu.Unlock()
+
+	return ctx, func() {
+		c.mu.Lock()
+		delete(c.cancels, key)
+		c.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancel cancels the in-flight request with the given ID. It reports whether
+// such a request was found.
+func (c *conn) cancel(id json.RawMessage) bool {
+	c.mu.Lock()
+	cancel, ok := c.cancels[string(id)]
+	c.mu.Unlock()
+
+	if ok {
+		cancel(errCancelled)
+	}
+	return ok
+}
+
+// cancelAll cancels every in-flight request, e.g. when the connection closes.
+func (c *conn) cancelAll(cause error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, cancel := range c.cancels {
+		cancel(cause)
+	}
+}
+
+// CancelRequest cancels the context of the in-flight request with the given ID
+// on the connection ctx belongs to. The cancelled request is not answered. It
+// reports whether the request was found; requests that already finished or are
+// unknown are ignored.
+func CancelRequest(ctx context.Context, id json.RawMessage) bool {
+	c := connFromContext(ctx)
+	if c == nil {
+		return false
+	}
+
+	return c.cancel(id)
+}
@@ -0,0 +1,654 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HTTPEndpoint is the path of the single MCP endpoint.
+	HTTPEndpoint = "/mcp"
+	// SessionIDHeader carries the session ID assigned by the server at
+	// initialization.
+	SessionIDHeader = "Mcp-Session-Id"
+	// ProtocolVersionHeader carries the protocol version negotiated at
+	// initialization on later requests.
+	ProtocolVersionHeader = "MCP-Protocol-Version"
+
+	// DefaultIdleTimeout is the default time after which an HTTPServer
+	// closes a session the client stopped using.
+	DefaultIdleTimeout = 10 * time.Minute
+
+	// lastBatchVersion is the last protocol version allowing JSON-RPC
+	// batches.
+	lastBatchVersion = "2025-03-26"
+)
+
+// HTTPServer implements a JSON-RPC server over the MCP Streamable HTTP
+// transport. Clients POST messages to a single endpoint, open a stream for
+// server-initiated messages with GET and terminate their session with DELETE.
+// See: https://modelcontextprotocol.io/specification/2025-11-25/basic/transports#streamable-http
+type HTTPServer struct {
+	*Server
+
+	// addr is the TCP address to listen on.
+	addr string
+	// err is the error stream for logging errors.
+	err    io.Writer
+	logger *log.Logger
+
+	// JSONResponse makes the server answer POSTed requests with a single
+	// application/json response, even when the client accepts
+	// text/event-stream.
+	JSONResponse bool
+
+	// IdleTimeout closes sessions that have had no request in flight and no
+	// GET stream open for this long. Clients are expected to DELETE their
+	// session when done, but may just go away. Zero means
+	// DefaultIdleTimeout; a negative value disables the timeout.
+	IdleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+	// reaping is set while a goroutine closes idle sessions.
+	reaping bool
+}
+
+// httpSession holds the state of a session established by an initialize
+// request.
+type httpSession struct {
+	*conn
+
+	mu sync.Mutex
+	// stream receives server-initiated messages while the client has a GET
+	// stream open.
+	stream chan json.RawMessage
+	// version is the protocol version negotiated at initialization.
+	version string
+	// active counts the requests in flight, including GET streams.
+	active int
+	// lastActive is when the last request ended.
+	lastActive time.Time
+}
+
+// begin marks the start of a request of the session. The caller must call end
+// once the request is done.
+func (sess *httpSession) begin() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.active++
+}
+
+func (sess *httpSession) end() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.active--
+	sess.lastActive = time.Now()
+}
+
+// idle reports whether the session has had no request in flight for at least
+// d.
+func (sess *httpSession) idle(d time.Duration) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.active == 0 && time.Since(sess.lastActive) >= d
+}
+
+// protocolVersion returns the protocol version negotiated at initialization.
+func (sess *httpSession) protocolVersion() string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.version
+}
+
+// ErrNoStream is returned when a message cannot be sent because the client
+// has no stream open to receive it.
+var ErrNoStream = errors.New("jsonrpc: no open stream to the client")
+
+// sendToStream sends a message on the session's GET stream.
+func (sess *httpSession) sendToStream(ctx context.Context, msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	sess.mu.Lock()
+	stream := sess.stream
+	sess.mu.Unlock()
+
+	if stream == nil {
+		return ErrNoStream
+	}
+
+	select {
+	case stream <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-sess.done:
+		return ErrConnClosed
+	}
+}
+
+// errStreamClosed is returned when sending on an eventStream whose request has
+// been answered.
+var errStreamClosed = errors.New("jsonrpc: stream closed")
+
+// eventStream is the SSE stream answering a POSTed request. The stream is
+// started when the first message is sent, so a request that is never answered
+// can still be acknowledged with 202 Accepted.
+type eventStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	started bool
+	closed  bool
+}
+
+func (e *eventStream) send(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return errStreamClosed
+	}
+	if !e.started {
+		startStream(e.w)
+		e.started = true
+	}
+
+	return writeEvent(e.w, data)
+}
+
+// isStarted reports whether anything has been sent on the stream.
+func (e *eventStream) isStarted() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.started
+}
+
+// close closes the stream. It reports whether the stream was ever started.
+func (e *eventStream) close() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	return e.started
+}
+
+// streamSession is the Session of a request answered with an SSE stream.
+// Messages the handler sends while the request is in flight go on that
+// stream; later ones fall back to the session's GET stream.
+type streamSession struct {
+	*conn
+	stream *eventStream
+}
+
+func (s *streamSession) send(ctx context.Context, msg any) error {
+	err := s.stream.send(msg)
+	if errors.Is(err, errStreamClosed) {
+		return s.conn.send(ctx, msg)
+	}
+	return err
+}
+
+// Call implements Session.
+func (s *streamSession) Call(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+	return s.conn.call(ctx, s.send, method, params)
+}
+
+// Notify implements Session.
+func (s *streamSession) Notify(ctx context.Context, method string, params json.RawMessage) error {
+	return s.conn.notify(ctx, s.send, method, params)
+}
+
+// NewHTTPServer creates a new HTTPServer listening on addr that logs errors to
+// err.
+func NewHTTPServer(addr string, err io.Writer) *HTTPServer {
+	return &HTTPServer{
+		Server:   NewServer(),
+		addr:     addr,
+		err:      err,
+		logger:   log.New(err, "jsonrpc: ", log.LstdFlags),
+		sessions: make(map[string]*httpSession),
+	}
+}
+
+// Serve listens on the server's address and serves the MCP endpoint at
+// HTTPEndpoint.
+func (s *HTTPServer) Serve() error {
+	mux := http.NewServeMux()
+	mux.Handle(HTTPEndpoint, s)
+
+	srv := &http.Server{
+		Addr:     s.addr,
+		Handler:  mux,
+		ErrorLog: s.logger,
+	}
+
+	s.logger.Printf("listening on http://%s%s", s.addr, HTTPEndpoint)
+	return srv.ListenAndServe()
+}
+
+// ServeHTTP implements http.Handler for the MCP endpoint.
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Servers MUST validate the Origin header to prevent DNS rebinding
+	// attacks.
+	if !validOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodGet:
+		s.handleGet(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *HTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r, "application/json") && !accepts(r, "text/event-stream") {
+		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if isBatch(body) {
+		s.handlePostBatch(w, r, body)
+		return
+	}
+
+	msg, errResp := parseMessage(s.logger, body)
+	if errResp != nil {
+		writeJSON(w, http.StatusBadRequest, errResp)
+		return
+	}
+
+	initialize := msg.Method == "initialize"
+
+	var sess *httpSession
+	if initialize {
+		sess = s.newSession()
+		w.Header().Set(SessionIDHeader, sess.id)
+	} else {
+		var ok bool
+		sess, ok = s.session(w, r)
+		if !ok {
+			return
+		}
+	}
+	sess.begin()
+	defer sess.end()
+
+	ctx := withConn(r.Context(), sess.conn)
+
+	// Notifications and responses to server-initiated requests are
+	// acknowledged without a body.
+	if msg.isResponse() || msg.ID == nil {
+		s.handleMessage(ctx, s.logger, msg)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// endInitialize records the negotiated protocol version, or forgets the
+	// session if initialization failed.
+	endInitialize := func(resp *Response, started bool) {
+		if !initialize || resp == nil {
+			return
+		}
+		if resp.Error == nil {
+			var result struct {
+				ProtocolVersion string `json:"protocolVersion"`
+			}
+			json.Unmarshal(resp.Result, &result)
+			sess.mu.Lock()
+			sess.version = result.ProtocolVersion
+			sess.mu.Unlock()
+			return
+		}
+
+		s.closeSession(sess.id)
+		if !started {
+			w.Header().Del(SessionIDHeader)
+		}
+	}
+
+	if (s.JSONResponse && accepts(r, "application/json")) || !accepts(r, "text/event-stream") {
+		resp := s.handleMessage(ctx, s.logger, msg)
+		endInitialize(resp, false)
+		if resp == nil {
+			// The client cancelled the request.
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	stream := &eventStream{w: w}
+	ctx = withSession(ctx, &streamSession{conn: sess.conn, stream: stream})
+
+	resp := s.handleMessage(ctx, s.logger, msg)
+	if resp != nil {
+		endInitialize(resp, stream.isStarted())
+		err = stream.send(resp)
+		if err != nil {
+			s.logger.Printf("Failed to write event: %v", err)
+		}
+	}
+
+	if !stream.close() {
+		// The client cancelled the request before anything was sent.
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// handlePostBatch answers a POSTed JSON-RPC batch with a single JSON response.
+// Batches were removed from the protocol after 2025-03-26, and can't
+// initialize a session.
+func (s *HTTPServer) handlePostBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	sess, ok := s.session(w, r)
+	if !ok {
+		return
+	}
+	if version := sess.protocolVersion(); version > lastBatchVersion {
+		writeJSON(w, http.StatusBadRequest, NewErrorResponse(nil, &Error{
+			Code:    CodeInvalidRequest,
+			Message: "Invalid request: batches are not supported in protocol version " + version,
+		}))
+		return
+	}
+	sess.begin()
+	defer sess.end()
+
+	resps, errResp := s.handleBatch(withConn(r.Context(), sess.conn), s.logger, body)
+	if errResp != nil {
+		writeJSON(w, http.StatusBadRequest, errResp)
+		return
+	}
+	if len(resps) == 0 {
+		// The batch only held notifications and responses, or its requests
+		// were cancelled.
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resps)
+}
+
+func (s *HTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r, "text/event-stream") {
+		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		return
+	}
+
+	sess, ok := s.session(w, r)
+	if !ok {
+		return
+	}
+
+	stream := make(chan json.RawMessage, 16)
+	sess.mu.Lock()
+	if sess.stream != nil {
+		sess.mu.Unlock()
+		http.Error(w, "Stream already open", http.StatusConflict)
+		return
+	}
+	sess.stream = stream
+	sess.mu.Unlock()
+
+	// An open stream keeps the session alive.
+	sess.begin()
+	defer sess.end()
+
+	defer func() {
+		sess.mu.Lock()
+		sess.stream = nil
+		sess.mu.Unlock()
+	}()
+
+	startStream(w)
+	for {
+		select {
+		case msg := <-stream:
+			err := writeEvent(w, msg)
+			if err != nil {
+				s.logger.Printf("Failed to write event: %v", err)
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-sess.done:
+			return
+		}
+	}
+}
+
+func (s *HTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.session(w, r)
+	if !ok {
+		return
+	}
+
+	s.closeSession(sess.id)
+	w.WriteHeader(http.StatusOK)
+}
+
+// newSession creates and registers a session with a new random ID.
+func (s *HTTPServer) newSession() *httpSession {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+
+	id := hex.EncodeToString(buf)
+	sess := &httpSession{lastActive: time.Now()}
+	sess.conn = newConn(id, sess.sendToStream, func() {
+		s.closeSession(id)
+	})
+
+	s.mu.Lock()
+	s.sessions[sess.id] = sess
+	timeout := s.idleTimeout()
+	reap := timeout > 0 && !s.reaping
+	if reap {
+		s.reaping = true
+	}
+	s.mu.Unlock()
+
+	if reap {
+		go s.reapSessions(timeout)
+	}
+
+	return sess
+}
+
+func (s *HTTPServer) idleTimeout() time.Duration {
+	if s.IdleTimeout == 0 {
+		return DefaultIdleTimeout
+	}
+	return s.IdleTimeout
+}
+
+// reapSessions closes the sessions idle for timeout until no session is left.
+func (s *HTTPServer) reapSessions(timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var idle []string
+		s.mu.Lock()
+		for id, sess := range s.sessions {
+			if sess.idle(timeout) {
+				idle = append(idle, id)
+			}
+		}
+		// Once every session is gone, the next new session starts over.
+		last := len(idle) == len(s.sessions)
+		if last {
+			s.reaping = false
+		}
+		s.mu.Unlock()
+
+		for _, id := range idle {
+			s.logger.Printf("Closing session %s idle for %s", id, timeout)
+			s.closeSession(id)
+		}
+		if last {
+			return
+		}
+	}
+}
+
+// session returns the session identified by the request's session header. It
+// writes an error response and returns false if the header is missing, the
+// session does not exist or the request's protocol version header differs
+// from the version negotiated for the session.
+func (s *HTTPServer) session(w http.ResponseWriter, r *http.Request) (*httpSession, bool) {
+	id := r.Header.Get(SessionIDHeader)
+	if id == "" {
+		http.Error(w, "Missing "+SessionIDHeader+" header", http.StatusBadRequest)
+		return nil, false
+	}
+
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	s.mu.Unlock()
+
+	// Clients MUST start a new session when they receive a 404 for their
+	// session ID.
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	// Without the header, the server assumes the negotiated version.
+	version := r.Header.Get(ProtocolVersionHeader)
+	if negotiated := sess.protocolVersion(); version != "" && negotiated != "" && version != negotiated {
+		http.Error(w, "Unsupported "+ProtocolVersionHeader+" "+version+", the session uses "+negotiated, http.StatusBadRequest)
+		return nil, false
+	}
+
+	return sess, true
+}
+
+// closeSession terminates and forgets the session with the given ID.
+func (s *HTTPServer) closeSession(id string) {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if ok {
+		sess.close()
+		sess.cancelAll(ErrSessionClosed)
+	}
+}
+
+// accepts reports whether the request's Accept header allows mediaType.
+func accepts(r *http.Request, mediaType string) bool {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return true
+	}
+
+	typ, _, _ := strings.Cut(mediaType, "/")
+	for _, header := range accept {
+		for _, part := range strings.Split(header, ",") {
+			part, _, _ = strings.Cut(part, ";")
+			part = strings.TrimSpace(part)
+			if part == mediaType || part == "*/*" || part == typ+"/*" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validOrigin reports whether the request's Origin, if any, is the server
+// itself or a loopback address.
+func validOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+
+	return u.Host == r.Host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// startStream writes the headers of a text/event-stream response.
+func startStream(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeEvent writes data as a single SSE message event and flushes it to the
+// client.
+func writeEvent(w http.ResponseWriter, data []byte) error {
+	_, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	if err != nil {
+		return err
+	}
+
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return nil
+}
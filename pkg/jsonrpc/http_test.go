@@ -0,0 +1,487 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHTTPServer(t *testing.T) (*HTTPServer, *httptest.Server) {
+	t.Helper()
+	s := NewHTTPServer("", io.Discard)
+	// The server agrees to the protocol version the client asks for.
+	s.RegisterMethod("initialize", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		return params, nil
+	})
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		return EmptyResult(), nil
+	})
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func post(t *testing.T, url, sessionID, accept, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if sessionID != "" {
+		req.Header.Set(SessionIDHeader, sessionID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func initialize(t *testing.T, url string) string {
+	t.Helper()
+	return initializeVersion(t, url, "")
+}
+
+// initializeVersion is like initialize, negotiating the given protocol
+// version unless it is empty.
+func initializeVersion(t *testing.T, url, version string) string {
+	t.Helper()
+	params := `{}`
+	if version != "" {
+		params = `{"protocolVersion":"` + version + `"}`
+	}
+	resp := post(t, url, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":`+params+`}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	id := resp.Header.Get(SessionIDHeader)
+	if id == "" {
+		t.Fatalf("initialize response has no %s header", SessionIDHeader)
+	}
+	return id
+}
+
+func TestHTTPInitializeCreatesSession(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	sessionID := initialize(t, ts.URL)
+
+	resp := post(t, ts.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var r Response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if string(r.ID) != "2" {
+		t.Errorf("ID = %s, want 2", r.ID)
+	}
+	if r.Error != nil {
+		t.Errorf("unexpected error: %v", r.Error)
+	}
+}
+
+func TestHTTPFailedInitializeHasNoSession(t *testing.T) {
+	s, ts := newTestHTTPServer(t)
+	s.RegisterMethod("initialize", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		return nil, &Error{Code: CodeInvalidParams, Message: "Invalid params"}
+	})
+
+	resp := post(t, ts.URL, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+	if id := resp.Header.Get(SessionIDHeader); id != "" {
+		t.Errorf("%s = %q, want none", SessionIDHeader, id)
+	}
+	if len(s.sessions) != 0 {
+		t.Errorf("got %d sessions, want 0", len(s.sessions))
+	}
+}
+
+func TestHTTPSessionRequired(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+
+	resp := post(t, ts.URL, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("missing session: status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp = post(t, ts.URL, "unknown", "application/json", `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session: status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestHTTPNotificationAccepted(t *testing.T) {
+	s, ts := newTestHTTPServer(t)
+	called := false
+	s.RegisterNotification("notifications/initialized", func(ctx context.Context, params json.RawMessage) {
+		called = true
+	})
+	sessionID := initialize(t, ts.URL)
+
+	resp := post(t, ts.URL, sessionID, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if !called {
+		t.Error("notification was not handled")
+	}
+}
+
+func TestHTTPEventStreamResponse(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	sessionID := initialize(t, ts.URL)
+
+	resp := post(t, ts.URL, sessionID, "application/json, text/event-stream", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	want := "event: message\ndata: {\"jsonrpc\":\"2.0\",\"result\":{},\"id\":2}\n\n"
+	if string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestHTTPJSONResponseOption(t *testing.T) {
+	s, ts := newTestHTTPServer(t)
+	s.JSONResponse = true
+	sessionID := initialize(t, ts.URL)
+
+	resp := post(t, ts.URL, sessionID, "application/json, text/event-stream", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+}
+
+func TestHTTPDeleteSession(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	sessionID := initialize(t, ts.URL)
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set(SessionIDHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp = post(t, ts.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status after DELETE = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestHTTPIdleSessionClosed(t *testing.T) {
+	s, ts := newTestHTTPServer(t)
+	s.IdleTimeout = 20 * time.Millisecond
+	idleID := initialize(t, ts.URL)
+	activeID := initialize(t, ts.URL)
+
+	// A session with an open GET stream is not idle.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionIDHeader, activeID)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer stream.Body.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	resp := post(t, ts.URL, idleID, "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("idle session status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	resp = post(t, ts.URL, activeID, "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("streaming session status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestHTTPProtocolVersionHeader(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	sessionID := initializeVersion(t, ts.URL, "2025-06-18")
+
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{"", http.StatusOK},
+		{"2025-06-18", http.StatusOK},
+		{"2025-03-26", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set(SessionIDHeader, sessionID)
+		if tt.header != "" {
+			req.Header.Set(ProtocolVersionHeader, tt.header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %q: status = %d, want %d", ProtocolVersionHeader, tt.header, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestHTTPBatch(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	sessionID := initializeVersion(t, ts.URL, "2025-03-26")
+
+	resp := post(t, ts.URL, sessionID, "application/json, text/event-stream",
+		`[{"jsonrpc":"2.0","id":2,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":3,"method":"ping"}]`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var resps []Response
+	if err := json.NewDecoder(resp.Body).Decode(&resps); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(resps) != 2 || string(resps[0].ID) != "2" || string(resps[1].ID) != "3" {
+		t.Errorf("responses = %+v, want answers to 2 and 3", resps)
+	}
+
+	resp = post(t, ts.URL, sessionID, "application/json", `[{"jsonrpc":"2.0","method":"notifications/initialized"}]`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notifications only: status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+}
+
+func TestHTTPBatchAfter20250326(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	sessionID := initializeVersion(t, ts.URL, "2025-06-18")
+
+	resp := post(t, ts.URL, sessionID, "application/json", `[{"jsonrpc":"2.0","id":2,"method":"ping"}]`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHTTPGetStream(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	sessionID := initialize(t, ts.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionIDHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// A second stream for the same session is rejected.
+	req2, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req2.Header.Set("Accept", "text/event-stream")
+	req2.Header.Set(SessionIDHeader, sessionID)
+	resp2, err := http.DefaultClient.Do(req2)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusConflict {
+		t.Errorf("second stream status = %d, want %d", resp2.StatusCode, http.StatusConflict)
+	}
+}
+
+func TestHTTPForbiddenOrigin(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+	req.Header.Set("Origin", "https://evil.example")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		accept    string
+		mediaType string
+		want      bool
+	}{
+		{"", "application/json", true},
+		{"application/json", "application/json", true},
+		{"application/json, text/event-stream", "text/event-stream", true},
+		{"text/*;q=0.5", "text/event-stream", true},
+		{"*/*", "application/json", true},
+		{"text/html", "application/json", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := accepts(r, tt.mediaType); got != tt.want {
+			t.Errorf("accepts(%q, %q) = %v, want %v", tt.accept, tt.mediaType, got, tt.want)
+		}
+	}
+}
+
+// readEvent reads the data of the next SSE event from r.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" && data != "" {
+			return data
+		}
+		if d, ok := strings.CutPrefix(line, "data: "); ok {
+			data = d
+		}
+	}
+}
+
+func TestHTTPSessionCallOnRequestStream(t *testing.T) {
+	s, ts := newTestHTTPServer(t)
+	s.RegisterMethod("ask", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		result, err := SessionFromContext(ctx).Call(ctx, "roots/list", nil)
+		if err != nil {
+			return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return result, nil
+	})
+	sessionID := initialize(t, ts.URL)
+
+	resp := post(t, ts.URL, sessionID, "application/json, text/event-stream", `{"jsonrpc":"2.0","id":2,"method":"ask"}`)
+	events := bufio.NewReader(resp.Body)
+
+	// The server's request arrives on the stream answering the POST.
+	var req Request
+	if err := json.Unmarshal([]byte(readEvent(t, events)), &req); err != nil {
+		t.Fatalf("Unmarshal request: %v", err)
+	}
+	if req.Method != "roots/list" {
+		t.Errorf("Method = %q, want roots/list", req.Method)
+	}
+
+	answer := post(t, ts.URL, sessionID, "application/json, text/event-stream",
+		`{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":{"roots":[]}}`)
+	if answer.StatusCode != http.StatusAccepted {
+		t.Errorf("response POST status = %d, want %d", answer.StatusCode, http.StatusAccepted)
+	}
+
+	var r Response
+	if err := json.Unmarshal([]byte(readEvent(t, events)), &r); err != nil {
+		t.Fatalf("Unmarshal response: %v", err)
+	}
+	if string(r.ID) != "2" {
+		t.Errorf("ID = %s, want 2", r.ID)
+	}
+	if string(r.Result) != `{"roots":[]}` {
+		t.Errorf("Result = %s, want %s", r.Result, `{"roots":[]}`)
+	}
+}
+
+func TestHTTPSessionNotifyOnGetStream(t *testing.T) {
+	s, ts := newTestHTTPServer(t)
+	sessions := make(chan Session, 1)
+	s.RegisterMethod("remember", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		sessions <- SessionFromContext(ctx)
+		return EmptyResult(), nil
+	})
+	sessionID := initialize(t, ts.URL)
+
+	post(t, ts.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":2,"method":"remember"}`)
+	session := <-sessions
+
+	// Without a GET stream there is nowhere to send the notification.
+	err := session.Notify(context.Background(), "notifications/tools/list_changed", nil)
+	if err != ErrNoStream {
+		t.Errorf("Notify without stream = %v, want %v", err, ErrNoStream)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionIDHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	err = session.Notify(context.Background(), "notifications/tools/list_changed", nil)
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var notification Notification
+	if err := json.Unmarshal([]byte(readEvent(t, bufio.NewReader(resp.Body))), &notification); err != nil {
+		t.Fatalf("Unmarshal notification: %v", err)
+	}
+	if notification.Method != "notifications/tools/list_changed" {
+		t.Errorf("Method = %q, want notifications/tools/list_changed", notification.Method)
+	}
+}
+
+func TestHTTPSessionClose(t *testing.T) {
+	s, ts := newTestHTTPServer(t)
+	s.RegisterMethod("quit", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		SessionFromContext(ctx).Close()
+		return EmptyResult(), nil
+	})
+	sessionID := initialize(t, ts.URL)
+
+	post(t, ts.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":2,"method":"quit"}`)
+
+	resp := post(t, ts.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status after Close = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
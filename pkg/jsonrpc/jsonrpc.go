@@ -2,21 +2,35 @@
 package jsonrpc
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 )
 
 const Version = "2.0"
 
-type Method func(params json.RawMessage) (json.RawMessage, *Error)
+// maxMessageSize limits the size of a single message read by a transport.
+const maxMessageSize = 4 << 20
+
+// Method handles a JSON-RPC request. ctx is cancelled when the client cancels
+// the request or the connection closes.
+type Method func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error)
+
+// NotificationHandler handles a JSON-RPC notification. Notifications are never
+// answered, so a handler has no result.
+type NotificationHandler func(ctx context.Context, params json.RawMessage)
 
 type Server struct {
-	methods map[string]Method
+	methods       map[string]Method
+	notifications map[string]NotificationHandler
 }
 
 func NewServer() *Server {
 	return &Server{
-		methods: make(map[string]Method),
+		methods:       make(map[string]Method),
+		notifications: make(map[string]NotificationHandler),
 	}
 }
 
@@ -24,10 +38,127 @@ func EmptyResult() json.RawMessage {
 	return json.RawMessage(`{}`)
 }
 
+// RegisterMethod registers a JSON-RPC method with the server.
 func (s *Server) RegisterMethod(name string, method Method) {
 	s.methods[name] = method
 }
 
+// RegisterNotification registers a JSON-RPC notification handler with the
+// server.
+func (s *Server) RegisterNotification(name string, handler NotificationHandler) {
+	s.notifications[name] = handler
+}
+
+// Handle processes a JSON-RPC request and returns a response. Notifications
+// are dispatched to their handler and Handle returns nil: the server MUST NOT
+// reply to a notification, even if the method is unknown. Handle also returns
+// nil if the client cancelled the request while it was being handled.
+func (s *Server) Handle(ctx context.Context, req *Request) *Response {
+	if req.ID == nil {
+		if handler, ok := s.notifications[req.Method]; ok {
+			handler(ctx, req.Params)
+		}
+		return nil
+	}
+
+	method, ok := s.methods[req.Method]
+	if !ok {
+		return NewErrorResponse(req.ID,
+			&Error{
+				Code:    CodeMethodNotFound,
+				Message: "Method not found",
+			},
+		)
+	}
+
+	if c := connFromContext(ctx); c != nil {
+		var done func()
+		ctx, done = c.track(ctx, req.ID)
+		defer done()
+	}
+
+	result, err := method(ctx, req.Params)
+
+	// A request cancelled by the client is not answered.
+	if errors.Is(context.Cause(ctx), errCancelled) {
+		return nil
+	}
+
+	if err != nil {
+		return NewErrorResponse(req.ID, err)
+	}
+
+	return NewResponse(req.ID, result)
+}
+
+// message is a decoded JSON-RPC message: a request, a notification or a
+// response to a request sent by the server.
+type message struct {
+	Request
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// isResponse reports whether the message is a response rather than a request.
+func (m *message) isResponse() bool {
+	return m.Method == "" && (m.Result != nil || m.Error != nil)
+}
+
+// handleMessage dispatches a message. Requests and notifications are handled
+// like Handle does. Responses are delivered to the Session.Call waiting for
+// them and are never answered.
+func (s *Server) handleMessage(ctx context.Context, logger *log.Logger, msg *message) *Response {
+	if !msg.isResponse() {
+		return s.Handle(ctx, &msg.Request)
+	}
+
+	c := connFromContext(ctx)
+	if c == nil || !c.deliver(&Response{
+		JSONRPC: msg.JSONRPC,
+		Result:  msg.Result,
+		Error:   msg.Error,
+		ID:      msg.ID,
+	}) {
+		logger.Printf("Dropping response to unknown request %s", msg.ID)
+	}
+
+	return nil
+}
+
+// parseMessage decodes and validates a single JSON-RPC message. It returns an
+// error response if the message is invalid.
+func parseMessage(logger *log.Logger, data []byte) (*message, *Response) {
+	if !json.Valid(data) {
+		logger.Printf("Failed to parse request: invalid JSON")
+		return nil, NewErrorResponse(nil, &Error{
+			Code:    CodeParseError,
+			Message: "Invalid request: invalid JSON",
+		})
+	}
+
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		logger.Printf("Failed to parse request: %v", err)
+		return nil, NewErrorResponse(nil, &Error{
+			Code:    CodeInvalidRequest,
+			Message: "Invalid request: not a request object",
+		})
+	}
+
+	// JSONRPC MUST be version 2.0
+	if msg.JSONRPC != Version {
+		logger.Printf("Invalid JSON-RPC version: %s", msg.JSONRPC)
+		return nil, NewErrorResponse(msg.ID, &Error{
+			Code:    CodeInvalidRequest,
+			Message: "Invalid request: unsupported JSON-RPC version",
+		})
+	}
+
+	// TODO: ID MUST be a string or integer
+
+	return &msg, nil
+}
+
 // Request represents a JSON-RPC request object.
 // A Request is a Notification if the ID is omitted.
 type Request struct {
@@ -129,3 +260,48 @@ func JSONRawMessage(v any) json.RawMessage {
 
 	return buf
 }
+
+// isBatch reports whether data is a JSON array, i.e. a batch of requests.
+func isBatch(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '['
+}
+
+// handleBatch processes a batch of requests and returns the responses to the
+// requests that have an ID. It returns no responses if the batch only contains
+// notifications. If the batch itself is invalid, handleBatch returns a single
+// error response instead.
+func (s *Server) handleBatch(ctx context.Context, logger *log.Logger, data []byte) ([]*Response, *Response) {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		logger.Printf("Failed to parse batch: %v", err)
+		return nil, NewErrorResponse(nil, &Error{
+			Code:    CodeParseError,
+			Message: "Invalid request: invalid JSON",
+		})
+	}
+
+	// An empty batch is a single invalid request, not an empty batch response.
+	if len(batch) == 0 {
+		return nil, NewErrorResponse(nil, &Error{
+			Code:    CodeInvalidRequest,
+			Message: "Invalid request: empty batch",
+		})
+	}
+
+	var resps []*Response
+	for _, data := range batch {
+		msg, errResp := parseMessage(logger, data)
+		if errResp != nil {
+			resps = append(resps, errResp)
+			continue
+		}
+
+		resp := s.handleMessage(ctx, logger, msg)
+		if resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+
+	return resps, nil
+}
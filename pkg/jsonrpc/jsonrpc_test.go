@@ -1,6 +1,7 @@
 package jsonrpc
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 )
@@ -162,7 +163,7 @@ func TestResponseJSON(t *testing.T) {
 func TestServerRegisterMethod(t *testing.T) {
 	s := NewServer()
 	called := false
-	s.RegisterMethod("test", func(params json.RawMessage) (json.RawMessage, *Error) {
+	s.RegisterMethod("test", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		called = true
 		return EmptyResult(), nil
 	})
@@ -171,7 +172,7 @@ func TestServerRegisterMethod(t *testing.T) {
 	if !ok {
 		t.Fatal("method not registered")
 	}
-	method(nil)
+	method(context.Background(), nil)
 	if !called {
 		t.Error("method was not called")
 	}
@@ -2,14 +2,23 @@ package jsonrpc
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
+	"sync"
 )
 
+// DefaultMaxConcurrent is the default number of requests a StdioServer
+// handles at the same time.
+const DefaultMaxConcurrent = 16
+
 // StdioServer implements a JSON-RPC server that communicates over standard
 // input and output.
 type StdioServer struct {
+	*Server
+
 	// in is the input stream for reading JSON-RPC requests.
 	in io.Reader
 	// out is the output stream for writing JSON-RPC responses.
@@ -17,95 +26,181 @@ type StdioServer struct {
 	// err is the error stream for logging errors.
 	err io.Writer
 
-	methods map[string]Method
+	// MaxConcurrent limits the number of requests handled at the same time.
+	// Reading from the input stream pauses while the limit is reached. Zero
+	// means DefaultMaxConcurrent.
+	MaxConcurrent int
+
+	// conn is the connection to the single client on the other end of the
+	// streams.
+	conn   *conn
+	logger *log.Logger
+
+	// closing is closed when the session is closed by the server.
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	// outMu serializes writes so that concurrent messages never interleave.
+	outMu sync.Mutex
+	enc   *json.Encoder
 }
 
 // NewStdioServer creates a new StdioServer with the given input, output, and
 // error streams.
 func NewStdioServer(in io.Reader, out io.Writer, err io.Writer) *StdioServer {
-	return &StdioServer{
+	s := &StdioServer{
+		Server:  NewServer(),
 		in:      in,
 		out:     out,
 		err:     err,
-		methods: make(map[string]Method),
+		logger:  log.New(err, "jsonrpc: ", log.LstdFlags),
+		enc:     json.NewEncoder(out),
+		closing: make(chan struct{}),
 	}
+	s.conn = newConn("stdio", func(ctx context.Context, msg any) error {
+		return s.write(msg)
+	}, s.closeSession)
+
+	return s
 }
 
-// RehgisterMethod registers a JSON-RPC method with the server.
-func (s *StdioServer) RegisterMethod(name string, method Method) {
-	s.methods[name] = method
+// closeSession makes Serve stop reading the input stream and cancels
+// in-flight requests.
+func (s *StdioServer) closeSession() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.conn.cancelAll(ErrSessionClosed)
 }
 
-// Handle processes a JSON-RPC request and returns a response.
-func (s *StdioServer) Handle(req *Request) *Response {
-	method, ok := s.methods[req.Method]
-	if !ok {
-		return NewErrorResponse(req.ID,
-			&Error{
-				Code:    CodeMethodNotFound,
-				Message: "Method not found",
-			},
-		)
-	}
+// write writes v as a single line to the output stream.
+func (s *StdioServer) write(v any) error {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
 
-	result, err := method(req.Params)
+	// Encode writes the value and its trailing newline in a single Write.
+	err := s.enc.Encode(v)
 	if err != nil {
-		return NewErrorResponse(req.ID, err)
+		s.logger.Printf("Failed to write message: %v", err)
 	}
-
-	return NewResponse(req.ID, result)
+	return err
 }
 
 // Serve starts the server and listens for incoming JSON-RPC requests on the
-// input stream. It processes each request and writes the corresponding response
-// to the output stream.
-func (s *StdioServer) Serve() {
-	logger := log.New(s.err, "jsonrpc: ", log.LstdFlags)
-
-	writeResponse := func(resp *Response) {
-		respBytes, err := json.Marshal(resp)
-		if err != nil {
-			logger.Printf("Failed to marshal response: %v", err)
-			return
-		}
-
-		s.out.Write(respBytes)
-		s.out.Write([]byte("\n"))
+// input stream. Each request is handled on its own goroutine, so responses may
+// be written in a different order than the requests were read. Notifications
+// are not answered. Batches are answered with a single batch response.
+// Notifications and responses to the server's own requests are handled as
+// they are read, outside the MaxConcurrent limit, so that a cancellation
+// reaches a request holding the last slot; notification handlers must not
+// block. Responses are delivered to the waiting Session.Call. When the input
+// stream is exhausted, Serve waits for in-flight requests to finish before
+// returning. If the session is closed by the server first, Serve stops reading
+// and returns ErrSessionClosed once in-flight requests are done.
+func (s *StdioServer) Serve() error {
+	logger := s.logger
+	ctx := withConn(context.Background(), s.conn)
+
+	maxConcurrent := s.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrent
+	}
+	sem := make(chan struct{}, maxConcurrent)
+
+	var wg sync.WaitGroup
+
+	// dispatch runs handle on a new goroutine once a slot is available.
+	dispatch := func(handle func()) {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			handle()
+		}()
 	}
 
-	scanner := bufio.NewScanner(s.in)
-	for scanner.Scan() {
-		var req Request
-		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
-			logger.Printf("Failed to parse request: %v", err)
-			writeResponse(NewErrorResponse(nil, &Error{
-				Code:    CodeParseError,
-				Message: "Invalid request: invalid JSON",
-			}))
-			continue
+	// Lines are read on a separate goroutine so that closing the session does
+	// not wait for the client to send anything.
+	lines := make(chan []byte)
+	var scanErr error
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(s.in)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			// The scanner reuses its buffer, so copy the line before handing it
+			// to another goroutine.
+			select {
+			case lines <- bytes.Clone(scanner.Bytes()):
+			case <-s.closing:
+				return
+			}
+		}
+		scanErr = scanner.Err()
+	}()
+
+	for {
+		var line []byte
+		var ok bool
+		select {
+		case line, ok = <-lines:
+		case <-s.closing:
+		}
+		if !ok {
+			break
 		}
 
-		// JSONRPC MUST be version 2.0
-		if req.JSONRPC != Version {
-			logger.Printf("Invalid JSON-RPC version: %s", req.JSONRPC)
-			writeResponse(NewErrorResponse(req.ID, &Error{
-				Code:    CodeInvalidRequest,
-				Message: "Invalid request: unsupported JSON-RPC version",
-			}))
+		if isBatch(line) {
+			dispatch(func() {
+				resps, errResp := s.handleBatch(ctx, logger, line)
+				if errResp != nil {
+					s.write(errResp)
+				} else if len(resps) > 0 {
+					s.write(resps)
+				}
+			})
 			continue
 		}
 
-		// TODO: ID MUST be a string or integer
+		msg, errResp := parseMessage(logger, line)
+		if errResp != nil {
+			s.write(errResp)
+			continue
+		}
 
-		resp := s.Handle(&req)
-		respBytes, err := json.Marshal(resp)
-		if err != nil {
-			logger.Printf("Failed to marshal response: %v", err)
+		// Responses and notifications are handled right away: the handlers
+		// waiting for a response, or for a cancellation, may hold every
+		// dispatch slot. Notification handlers must not block.
+		if msg.isResponse() || msg.ID == nil {
+			s.handleMessage(ctx, logger, msg)
 			continue
 		}
 
-		// Write response to the output stream
-		s.out.Write(respBytes)
-		s.out.Write([]byte("\n"))
+		dispatch(func() {
+			resp := s.handleMessage(ctx, logger, msg)
+			if resp != nil {
+				s.write(resp)
+			}
+		})
+	}
+
+	// No more responses can arrive once the input is exhausted, so fail
+	// pending calls instead of letting handlers wait for them to time out.
+	// The session ends once in-flight requests are done.
+	s.conn.hangUp()
+	wg.Wait()
+	s.conn.close()
+
+	select {
+	case <-s.closing:
+		return ErrSessionClosed
+	default:
 	}
+
+	// The reader closed lines after setting scanErr.
+	return scanErr
 }
@@ -2,10 +2,12 @@ package jsonrpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"strings"
 	"testing"
+	"time"
 )
 
 func newTestServer(input string) (*StdioServer, *bytes.Buffer) {
@@ -17,7 +19,7 @@ func newTestServer(input string) (*StdioServer, *bytes.Buffer) {
 
 func TestHandleMethodFound(t *testing.T) {
 	s, _ := newTestServer("")
-	s.RegisterMethod("ping", func(params json.RawMessage) (json.RawMessage, *Error) {
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		return EmptyResult(), nil
 	})
 
@@ -26,7 +28,7 @@ func TestHandleMethodFound(t *testing.T) {
 		Method:  "ping",
 		ID:      json.RawMessage(`1`),
 	}
-	resp := s.Handle(req)
+	resp := s.Handle(context.Background(), req)
 
 	if resp.Error != nil {
 		t.Fatalf("unexpected error: %v", resp.Error)
@@ -44,7 +46,7 @@ func TestHandleMethodNotFound(t *testing.T) {
 		Method:  "nonexistent",
 		ID:      json.RawMessage(`1`),
 	}
-	resp := s.Handle(req)
+	resp := s.Handle(context.Background(), req)
 
 	if resp.Error == nil {
 		t.Fatal("expected error, got nil")
@@ -56,7 +58,7 @@ func TestHandleMethodNotFound(t *testing.T) {
 
 func TestHandleMethodReturnsError(t *testing.T) {
 	s, _ := newTestServer("")
-	s.RegisterMethod("fail", func(params json.RawMessage) (json.RawMessage, *Error) {
+	s.RegisterMethod("fail", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		return nil, &Error{Code: CodeInternalError, Message: "boom"}
 	})
 
@@ -65,7 +67,7 @@ func TestHandleMethodReturnsError(t *testing.T) {
 		Method:  "fail",
 		ID:      json.RawMessage(`1`),
 	}
-	resp := s.Handle(req)
+	resp := s.Handle(context.Background(), req)
 
 	if resp.Error == nil {
 		t.Fatal("expected error, got nil")
@@ -80,7 +82,7 @@ func TestHandleMethodReturnsError(t *testing.T) {
 
 func TestHandlePassesParams(t *testing.T) {
 	s, _ := newTestServer("")
-	s.RegisterMethod("echo", func(params json.RawMessage) (json.RawMessage, *Error) {
+	s.RegisterMethod("echo", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		return params, nil
 	})
 
@@ -90,7 +92,7 @@ func TestHandlePassesParams(t *testing.T) {
 		Params:  json.RawMessage(`{"msg":"hello"}`),
 		ID:      json.RawMessage(`1`),
 	}
-	resp := s.Handle(req)
+	resp := s.Handle(context.Background(), req)
 
 	if resp.Error != nil {
 		t.Fatalf("unexpected error: %v", resp.Error)
@@ -103,7 +105,7 @@ func TestHandlePassesParams(t *testing.T) {
 func TestServeRoundTrip(t *testing.T) {
 	input := `{"jsonrpc":"2.0","id":1,"method":"ping","params":{}}` + "\n"
 	s, out := newTestServer(input)
-	s.RegisterMethod("ping", func(params json.RawMessage) (json.RawMessage, *Error) {
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		return EmptyResult(), nil
 	})
 
@@ -128,7 +130,7 @@ func TestServeMultipleRequests(t *testing.T) {
 	input := `{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n" +
 		`{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n"
 	s, out := newTestServer(input)
-	s.RegisterMethod("ping", func(params json.RawMessage) (json.RawMessage, *Error) {
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		return EmptyResult(), nil
 	})
 
@@ -156,7 +158,7 @@ func TestServeInvalidJSON(t *testing.T) {
 	in := strings.NewReader(input)
 	out := &bytes.Buffer{}
 	s := NewStdioServer(in, out, errBuf)
-	s.RegisterMethod("ping", func(params json.RawMessage) (json.RawMessage, *Error) {
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		return EmptyResult(), nil
 	})
 
@@ -212,3 +214,544 @@ func TestServeMethodNotFoundResponse(t *testing.T) {
 		t.Errorf("Error.Code = %d, want %d", resp.Error.Code, CodeMethodNotFound)
 	}
 }
+
+func TestServeBatch(t *testing.T) {
+	input := `[{"jsonrpc":"2.0","id":1,"method":"ping"},` +
+		`{"jsonrpc":"2.0","method":"notify"},` +
+		`{"jsonrpc":"2.0","id":2,"method":"unknown"}]` + "\n"
+	s, out := newTestServer(input)
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		return EmptyResult(), nil
+	})
+	notified := false
+	s.RegisterNotification("notify", func(ctx context.Context, params json.RawMessage) {
+		notified = true
+	})
+
+	s.Serve()
+
+	var resps []Response
+	if err := json.Unmarshal(out.Bytes(), &resps); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(resps) != 2 {
+		t.Fatalf("got %d responses, want 2", len(resps))
+	}
+	if string(resps[0].ID) != "1" || resps[0].Error != nil {
+		t.Errorf("response 0 = %+v, want success for id 1", resps[0])
+	}
+	if string(resps[1].ID) != "2" || resps[1].Error == nil || resps[1].Error.Code != CodeMethodNotFound {
+		t.Errorf("response 1 = %+v, want method not found for id 2", resps[1])
+	}
+	if !notified {
+		t.Error("notification in batch was not handled")
+	}
+}
+
+func TestServeBatchOfNotifications(t *testing.T) {
+	input := `[{"jsonrpc":"2.0","method":"notify"},{"jsonrpc":"2.0","method":"notify"}]` + "\n"
+	s, out := newTestServer(input)
+	s.RegisterNotification("notify", func(ctx context.Context, params json.RawMessage) {})
+
+	s.Serve()
+
+	if out.Len() != 0 {
+		t.Errorf("output = %q, want nothing", out.String())
+	}
+}
+
+func TestServeInvalidBatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		codes []int
+		batch bool
+	}{
+		{"empty batch", `[]`, []int{CodeInvalidRequest}, false},
+		{"invalid JSON", `[{"jsonrpc":"2.0","method":"ping"},`, []int{CodeParseError}, false},
+		{"non-object members", `[1,"two"]`, []int{CodeInvalidRequest, CodeInvalidRequest}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out := newTestServer(tt.input + "\n")
+			s.Serve()
+
+			var resps []Response
+			if tt.batch {
+				if err := json.Unmarshal(out.Bytes(), &resps); err != nil {
+					t.Fatalf("Unmarshal batch: %v", err)
+				}
+			} else {
+				var resp Response
+				if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+					t.Fatalf("Unmarshal: %v", err)
+				}
+				resps = append(resps, resp)
+			}
+
+			if len(resps) != len(tt.codes) {
+				t.Fatalf("got %d responses, want %d", len(resps), len(tt.codes))
+			}
+			for i, resp := range resps {
+				if resp.Error == nil || resp.Error.Code != tt.codes[i] {
+					t.Errorf("response %d error = %v, want code %d", i, resp.Error, tt.codes[i])
+				}
+				if string(resp.ID) != "null" {
+					t.Errorf("response %d ID = %s, want null", i, resp.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleNotification(t *testing.T) {
+	s, _ := newTestServer("")
+	var got json.RawMessage
+	s.RegisterNotification("notify", func(ctx context.Context, params json.RawMessage) {
+		got = params
+	})
+
+	resp := s.Handle(context.Background(), &Request{
+		JSONRPC: Version,
+		Method:  "notify",
+		Params:  json.RawMessage(`{"a":1}`),
+	})
+
+	if resp != nil {
+		t.Errorf("response = %+v, want nil", resp)
+	}
+	if string(got) != `{"a":1}` {
+		t.Errorf("params = %s, want %s", got, `{"a":1}`)
+	}
+}
+
+func TestServeDoesNotAnswerNotifications(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"notify"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"unknown"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"ping"}` + "\n" +
+		`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n"
+	s, out := newTestServer(input)
+	notified := false
+	s.RegisterNotification("notify", func(ctx context.Context, params json.RawMessage) {
+		notified = true
+	})
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		return EmptyResult(), nil
+	})
+
+	s.Serve()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d responses, want 1: %q", len(lines), out.String())
+	}
+
+	var resp Response
+	if err := json.Unmarshal([]byte(lines[0]), &resp); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if string(resp.ID) != "1" {
+		t.Errorf("ID = %s, want 1", resp.ID)
+	}
+	if !notified {
+		t.Error("notification was not handled")
+	}
+}
+
+func TestServeConcurrentRequests(t *testing.T) {
+	input := `{"jsonrpc":"2.0","id":1,"method":"slow"}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n"
+	outR, outW := io.Pipe()
+	s := NewStdioServer(strings.NewReader(input), outW, io.Discard)
+
+	release := make(chan struct{})
+	s.RegisterMethod("slow", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		<-release
+		return EmptyResult(), nil
+	})
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		return EmptyResult(), nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.Serve()
+		outW.Close()
+		close(done)
+	}()
+
+	dec := json.NewDecoder(outR)
+
+	// ping is answered while slow is still running.
+	var first Response
+	if err := dec.Decode(&first); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if string(first.ID) != "2" {
+		t.Errorf("first response ID = %s, want 2", first.ID)
+	}
+
+	close(release)
+
+	// Serve waits for slow to finish before returning at EOF.
+	var second Response
+	if err := dec.Decode(&second); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if string(second.ID) != "1" {
+		t.Errorf("second response ID = %s, want 1", second.ID)
+	}
+
+	<-done
+}
+
+func TestServeMaxConcurrent(t *testing.T) {
+	input := `{"jsonrpc":"2.0","id":1,"method":"slow"}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n"
+	s, out := newTestServer(input)
+	s.MaxConcurrent = 1
+
+	s.RegisterMethod("slow", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		time.Sleep(50 * time.Millisecond)
+		return EmptyResult(), nil
+	})
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		return EmptyResult(), nil
+	})
+
+	s.Serve()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d responses, want 2", len(lines))
+	}
+
+	// With a single slot, ping is only read once slow has finished.
+	for i, want := range []string{"1", "2"} {
+		var resp Response
+		if err := json.Unmarshal([]byte(lines[i]), &resp); err != nil {
+			t.Fatalf("response %d: Unmarshal: %v", i, err)
+		}
+		if string(resp.ID) != want {
+			t.Errorf("response %d ID = %s, want %s", i, resp.ID, want)
+		}
+	}
+}
+
+func TestServeCancelRequest(t *testing.T) {
+	input := `{"jsonrpc":"2.0","id":1,"method":"slow"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"cancel","params":1}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n"
+	s, out := newTestServer(input)
+
+	started := make(chan struct{})
+	cancelled := false
+	s.RegisterMethod("slow", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		close(started)
+		<-ctx.Done()
+		cancelled = true
+		return nil, &Error{Code: CodeInternalError, Message: ctx.Err().Error()}
+	})
+	s.RegisterNotification("cancel", func(ctx context.Context, params json.RawMessage) {
+		<-started
+		if !CancelRequest(ctx, params) {
+			t.Errorf("CancelRequest(%s) = false, want true", params)
+		}
+	})
+	s.RegisterMethod("ping", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		return EmptyResult(), nil
+	})
+
+	s.Serve()
+
+	if !cancelled {
+		t.Error("request context was not cancelled")
+	}
+
+	// The cancelled request is not answered.
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d responses, want 1: %q", len(lines), out.String())
+	}
+
+	var resp Response
+	if err := json.Unmarshal([]byte(lines[0]), &resp); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if string(resp.ID) != "2" {
+		t.Errorf("ID = %s, want 2", resp.ID)
+	}
+}
+
+func TestServeCancelWhenSaturated(t *testing.T) {
+	input := `{"jsonrpc":"2.0","id":1,"method":"slow"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"cancel","params":1}` + "\n"
+	s, out := newTestServer(input)
+	s.MaxConcurrent = 1
+
+	started := make(chan struct{})
+	cancelled := false
+	s.RegisterMethod("slow", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			cancelled = true
+		case <-time.After(5 * time.Second):
+		}
+		return EmptyResult(), nil
+	})
+	// slow holds the only slot until it is cancelled, so the cancellation
+	// must not wait for one.
+	s.RegisterNotification("cancel", func(ctx context.Context, params json.RawMessage) {
+		<-started
+		CancelRequest(ctx, params)
+	})
+
+	s.Serve()
+
+	if !cancelled {
+		t.Error("request context was not cancelled")
+	}
+	if out.Len() != 0 {
+		t.Errorf("output = %q, want no response to the cancelled request", out.String())
+	}
+}
+
+func TestCancelUnknownRequest(t *testing.T) {
+	ctx := withConn(context.Background(), newConn("test", nil, nil))
+	if CancelRequest(ctx, json.RawMessage(`42`)) {
+		t.Error("CancelRequest of unknown request = true, want false")
+	}
+	if CancelRequest(context.Background(), json.RawMessage(`42`)) {
+		t.Error("CancelRequest without connection = true, want false")
+	}
+}
+
+// newPipeServer returns a server serving on pipes, a writer for the client's
+// messages and a decoder for the server's messages. Closing the writer stops
+// the server.
+func newPipeServer(t *testing.T, register func(s *StdioServer)) (io.WriteCloser, *json.Decoder) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := NewStdioServer(inR, outW, io.Discard)
+	register(s)
+
+	done := make(chan struct{})
+	go func() {
+		s.Serve()
+		outW.Close()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		io.Copy(io.Discard, outR)
+		<-done
+	})
+
+	return inW, json.NewDecoder(outR)
+}
+
+func TestSessionCall(t *testing.T) {
+	in, dec := newPipeServer(t, func(s *StdioServer) {
+		s.RegisterMethod("ask", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+			result, err := SessionFromContext(ctx).Call(ctx, "roots/list", nil)
+			if err != nil {
+				return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+			}
+			return result, nil
+		})
+	})
+
+	io.WriteString(in, `{"jsonrpc":"2.0","id":"a","method":"ask"}`+"\n")
+
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		t.Fatalf("Decode request: %v", err)
+	}
+	if req.Method != "roots/list" {
+		t.Errorf("Method = %q, want roots/list", req.Method)
+	}
+	if req.ID == nil {
+		t.Fatal("server request has no ID")
+	}
+
+	io.WriteString(in, `{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":{"roots":[]}}`+"\n")
+
+	var resp Response
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatalf("Decode response: %v", err)
+	}
+	if string(resp.ID) != `"a"` {
+		t.Errorf("ID = %s, want %q", resp.ID, "a")
+	}
+	if string(resp.Result) != `{"roots":[]}` {
+		t.Errorf("Result = %s, want %s", resp.Result, `{"roots":[]}`)
+	}
+}
+
+func TestSessionCallErrorResponse(t *testing.T) {
+	errCh := make(chan error, 1)
+	in, dec := newPipeServer(t, func(s *StdioServer) {
+		s.RegisterMethod("ask", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+			_, err := SessionFromContext(ctx).Call(ctx, "sampling/createMessage", nil)
+			errCh <- err
+			return EmptyResult(), nil
+		})
+	})
+
+	io.WriteString(in, `{"jsonrpc":"2.0","id":1,"method":"ask"}`+"\n")
+
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		t.Fatalf("Decode request: %v", err)
+	}
+	io.WriteString(in, `{"jsonrpc":"2.0","id":`+string(req.ID)+`,"error":{"code":-32601,"message":"Method not found"}}`+"\n")
+
+	err := <-errCh
+	rpcErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("error = %v (%T), want *Error", err, err)
+	}
+	if rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("Code = %d, want %d", rpcErr.Code, CodeMethodNotFound)
+	}
+}
+
+func TestSessionCallTimeout(t *testing.T) {
+	errCh := make(chan error, 1)
+	in, dec := newPipeServer(t, func(s *StdioServer) {
+		s.RegisterMethod("ask", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			_, err := SessionFromContext(ctx).Call(ctx, "elicitation/create", nil)
+			errCh <- err
+			return EmptyResult(), nil
+		})
+	})
+
+	io.WriteString(in, `{"jsonrpc":"2.0","id":1,"method":"ask"}`+"\n")
+
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		t.Fatalf("Decode request: %v", err)
+	}
+
+	// The server tells the client it stopped waiting.
+	var cancelled Notification
+	if err := dec.Decode(&cancelled); err != nil {
+		t.Fatalf("Decode notification: %v", err)
+	}
+	if cancelled.Method != "notifications/cancelled" {
+		t.Errorf("Method = %q, want notifications/cancelled", cancelled.Method)
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	json.Unmarshal(cancelled.Params, &params)
+	if string(params.RequestID) != string(req.ID) {
+		t.Errorf("requestId = %s, want %s", params.RequestID, req.ID)
+	}
+
+	if err := <-errCh; err != context.DeadlineExceeded {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSessionCallFailsOnEOF(t *testing.T) {
+	errCh := make(chan error, 1)
+	in, dec := newPipeServer(t, func(s *StdioServer) {
+		s.RegisterMethod("ask", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+			_, err := SessionFromContext(ctx).Call(ctx, "roots/list", nil)
+			errCh <- err
+			return EmptyResult(), nil
+		})
+	})
+
+	io.WriteString(in, `{"jsonrpc":"2.0","id":1,"method":"ask"}`+"\n")
+
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		t.Fatalf("Decode request: %v", err)
+	}
+	in.Close()
+
+	select {
+	case err := <-errCh:
+		if err != ErrConnClosed {
+			t.Errorf("error = %v, want %v", err, ErrConnClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Call did not fail after EOF")
+	}
+}
+
+func TestSessionNotify(t *testing.T) {
+	in, dec := newPipeServer(t, func(s *StdioServer) {
+		s.RegisterMethod("work", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+			err := SessionFromContext(ctx).Notify(ctx, "notifications/message", json.RawMessage(`{"level":"info"}`))
+			if err != nil {
+				return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+			}
+			return EmptyResult(), nil
+		})
+	})
+
+	io.WriteString(in, `{"jsonrpc":"2.0","id":1,"method":"work"}`+"\n")
+
+	var notification Notification
+	if err := dec.Decode(&notification); err != nil {
+		t.Fatalf("Decode notification: %v", err)
+	}
+	if notification.Method != "notifications/message" {
+		t.Errorf("Method = %q, want notifications/message", notification.Method)
+	}
+
+	var resp Response
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatalf("Decode response: %v", err)
+	}
+	if resp.Error != nil {
+		t.Errorf("unexpected error: %v", resp.Error)
+	}
+}
+
+func TestSessionClose(t *testing.T) {
+	inR, inW := io.Pipe()
+	defer inW.Close()
+	s := NewStdioServer(inR, io.Discard, io.Discard)
+
+	cause := make(chan error, 1)
+	s.RegisterMethod("quit", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+		SessionFromContext(ctx).Close()
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return EmptyResult(), nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Serve()
+	}()
+
+	// The input stays open: Serve must return without waiting for EOF.
+	io.WriteString(inW, `{"jsonrpc":"2.0","id":1,"method":"quit"}`+"\n")
+
+	select {
+	case err := <-errCh:
+		if err != ErrSessionClosed {
+			t.Errorf("Serve() = %v, want %v", err, ErrSessionClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after Close")
+	}
+	if err := <-cause; err != ErrSessionClosed {
+		t.Errorf("context cause = %v, want %v", err, ErrSessionClosed)
+	}
+	select {
+	case <-s.conn.Done():
+	default:
+		t.Error("session not done after Serve returned")
+	}
+}
@@ -0,0 +1,350 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrTableNotFound is returned by DescribeTable and PrimaryKey if there is no
+// such table.
+var ErrTableNotFound = errors.New("table not found")
+
+// TableName identifies a table, view or other relation with columns.
+type TableName struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+}
+
+func (t TableName) String() string {
+	return t.Schema + "." + t.Name
+}
+
+// Catalog lists the tables of a database.
+type Catalog struct {
+	// Database is the name of the database.
+	Database string
+	// Tables holds the tables of the user schemas, ordered by schema and
+	// name.
+	Tables []TableName
+	// Versions holds a hash of the definition of each table: its columns,
+	// constraints, indexes and comment. It changes when the table is altered.
+	Versions map[TableName]string
+}
+
+// relationKinds are the pg_class.relkind values of relations with columns the
+// user can query: tables, partitioned tables, views, materialized views and
+// foreign tables.
+const relationKinds = `('r', 'p', 'v', 'm', 'f')`
+
+// LoadCatalog lists the tables of the database that are not in a system
+// schema, with their versions.
+func LoadCatalog(ctx context.Context, db *pgxpool.Pool) (*Catalog, error) {
+	var catalog Catalog
+	err := db.QueryRow(ctx, `SELECT current_database()`).Scan(&catalog.Database)
+	if err != nil {
+		return nil, fmt.Errorf("querying database name: %w", err)
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT n.nspname, c.relname, md5(concat_ws('|',
+			(SELECT string_agg(concat_ws(' ', a.attname, format_type(a.atttypid, a.atttypmod),
+					a.attnotnull, pg_get_expr(d.adbin, d.adrelid), col_description(a.attrelid, a.attnum)),
+					',' ORDER BY a.attnum)
+				FROM pg_catalog.pg_attribute a
+				LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+				WHERE a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped),
+			(SELECT string_agg(co.conname || ' ' || pg_get_constraintdef(co.oid), ',' ORDER BY co.conname)
+				FROM pg_catalog.pg_constraint co
+				WHERE co.conrelid = c.oid),
+			(SELECT string_agg(pg_get_indexdef(x.indexrelid), ',' ORDER BY x.indexrelid)
+				FROM pg_catalog.pg_index x
+				WHERE x.indrelid = c.oid),
+			obj_description(c.oid, 'pg_class')
+		))
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN `+relationKinds+`
+			AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg\_toast%'
+			AND n.nspname NOT LIKE 'pg\_temp\_%'
+		ORDER BY n.nspname, c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("querying tables: %w", err)
+	}
+
+	catalog.Versions = make(map[TableName]string)
+	var table TableName
+	var version string
+	_, err = pgx.ForEachRow(rows, []any{&table.Schema, &table.Name, &version}, func() error {
+		catalog.Tables = append(catalog.Tables, table)
+		catalog.Versions[table] = version
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading tables: %w", err)
+	}
+
+	return &catalog, nil
+}
+
+// TableColumn describes a column of a table.
+type TableColumn struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Nullable bool    `json:"nullable"`
+	Default  *string `json:"default,omitempty"`
+	Comment  *string `json:"comment,omitempty"`
+}
+
+// Constraint describes a table constraint. Type is one of "primary key",
+// "foreign key", "unique", "check" and "exclusion".
+type Constraint struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Definition string `json:"definition"`
+}
+
+// Index describes an index of a table. Definition is the CREATE INDEX
+// statement.
+type Index struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// Table describes the structure of a table.
+type Table struct {
+	TableName
+	// Kind is one of "table", "partitioned table", "view", "materialized
+	// view" and "foreign table".
+	Kind        string        `json:"kind"`
+	Comment     *string       `json:"comment,omitempty"`
+	Columns     []TableColumn `json:"columns"`
+	Constraints []Constraint  `json:"constraints"`
+	Indexes     []Index       `json:"indexes"`
+}
+
+var relationKindNames = map[string]string{
+	"r": "table",
+	"p": "partitioned table",
+	"v": "view",
+	"m": "materialized view",
+	"f": "foreign table",
+}
+
+var constraintTypeNames = map[string]string{
+	"p": "primary key",
+	"f": "foreign key",
+	"u": "unique",
+	"c": "check",
+	"x": "exclusion",
+	"t": "trigger",
+	"n": "not null",
+}
+
+// DescribeTable returns the columns, constraints and indexes of a table. It
+// returns ErrTableNotFound if there is no such table.
+func DescribeTable(ctx context.Context, db *pgxpool.Pool, name TableName) (*Table, error) {
+	table := Table{TableName: name}
+
+	var oid uint32
+	err := db.QueryRow(ctx, `
+		SELECT c.oid, c.relkind::text, obj_description(c.oid, 'pg_class')
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN `+relationKinds,
+		name.Schema, name.Name,
+	).Scan(&oid, &table.Kind, &table.Comment)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTableNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying table: %w", err)
+	}
+	table.Kind = relationKindNames[table.Kind]
+
+	rows, err := db.Query(ctx, `
+		SELECT a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull,
+			pg_get_expr(d.adbin, d.adrelid), col_description(a.attrelid, a.attnum)
+		FROM pg_catalog.pg_attribute a
+		LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, oid)
+	if err != nil {
+		return nil, fmt.Errorf("querying columns: %w", err)
+	}
+	table.Columns, err = pgx.CollectRows(rows, pgx.RowToStructByPos[TableColumn])
+	if err != nil {
+		return nil, fmt.Errorf("reading columns: %w", err)
+	}
+
+	rows, err = db.Query(ctx, `
+		SELECT conname, contype::text, pg_get_constraintdef(oid)
+		FROM pg_catalog.pg_constraint
+		WHERE conrelid = $1
+		ORDER BY contype = 'p' DESC, conname`, oid)
+	if err != nil {
+		return nil, fmt.Errorf("querying constraints: %w", err)
+	}
+	table.Constraints, err = pgx.CollectRows(rows, pgx.RowToStructByPos[Constraint])
+	if err != nil {
+		return nil, fmt.Errorf("reading constraints: %w", err)
+	}
+	for i, constraint := range table.Constraints {
+		if typeName, ok := constraintTypeNames[constraint.Type]; ok {
+			table.Constraints[i].Type = typeName
+		}
+	}
+
+	rows, err = db.Query(ctx, `
+		SELECT i.relname, pg_get_indexdef(x.indexrelid)
+		FROM pg_catalog.pg_index x
+		JOIN pg_catalog.pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = $1
+		ORDER BY x.indisprimary DESC, i.relname`, oid)
+	if err != nil {
+		return nil, fmt.Errorf("querying indexes: %w", err)
+	}
+	table.Indexes, err = pgx.CollectRows(rows, pgx.RowToStructByPos[Index])
+	if err != nil {
+		return nil, fmt.Errorf("reading indexes: %w", err)
+	}
+
+	return &table, nil
+}
+
+// String returns a readable description of the table in the style of psql's
+// \d command.
+func (t *Table) String() string {
+	var b strings.Builder
+
+	kind := t.Kind
+	if kind == "" {
+		kind = "table"
+	}
+	fmt.Fprintf(&b, "%s%s %q.%q\n", strings.ToUpper(kind[:1]), kind[1:], t.Schema, t.Name)
+	if t.Comment != nil {
+		fmt.Fprintf(&b, "%s\n", *t.Comment)
+	}
+
+	b.WriteString("\nColumns:\n")
+	for _, column := range t.Columns {
+		fmt.Fprintf(&b, "  %s %s", column.Name, column.Type)
+		if !column.Nullable {
+			b.WriteString(" NOT NULL")
+		}
+		if column.Default != nil {
+			fmt.Fprintf(&b, " DEFAULT %s", *column.Default)
+		}
+		if column.Comment != nil {
+			fmt.Fprintf(&b, " -- %s", *column.Comment)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(t.Constraints) > 0 {
+		b.WriteString("\nConstraints:\n")
+		for _, constraint := range t.Constraints {
+			fmt.Fprintf(&b, "  %s %s\n", constraint.Name, constraint.Definition)
+		}
+	}
+
+	if len(t.Indexes) > 0 {
+		b.WriteString("\nIndexes:\n")
+		for _, index := range t.Indexes {
+			fmt.Fprintf(&b, "  %s\n", index.Definition)
+		}
+	}
+
+	return b.String()
+}
+
+// ErrNoPrimaryKey is returned by RowByPrimaryKey if the table has no primary
+// key.
+var ErrNoPrimaryKey = errors.New("table has no primary key")
+
+// ErrInvalidKey is returned by RowByPrimaryKey if the number of key values
+// doesn't match the number of primary key columns.
+var ErrInvalidKey = errors.New("invalid primary key")
+
+// ErrRowNotFound is returned by RowByPrimaryKey if there is no row with the
+// given key.
+var ErrRowNotFound = errors.New("row not found")
+
+// SampleRows returns the first limit rows of a table, in no particular order.
+func SampleRows(ctx context.Context, db *pgxpool.Pool, table TableName, limit int) (*Result, error) {
+	identifier := pgx.Identifier{table.Schema, table.Name}.Sanitize()
+	return QueryReadOnly(ctx, db, "SELECT * FROM "+identifier+" LIMIT $1", limit, nil, limit)
+}
+
+// PrimaryKey returns the columns of the primary key of a table, in key order.
+// It returns ErrTableNotFound if there is no such table and ErrNoPrimaryKey if
+// the table has no primary key.
+func PrimaryKey(ctx context.Context, db *pgxpool.Pool, table TableName) ([]string, error) {
+	var oid *uint32
+	identifier := pgx.Identifier{table.Schema, table.Name}.Sanitize()
+	err := db.QueryRow(ctx, `SELECT to_regclass($1)::oid`, identifier).Scan(&oid)
+	if err != nil {
+		return nil, fmt.Errorf("querying table: %w", err)
+	}
+	if oid == nil {
+		return nil, ErrTableNotFound
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT a.attname
+		FROM pg_catalog.pg_index x
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = x.indrelid AND a.attnum = ANY(x.indkey)
+		WHERE x.indrelid = $1 AND x.indisprimary
+		ORDER BY array_position(x.indkey::int2[], a.attnum)`, *oid)
+	if err != nil {
+		return nil, fmt.Errorf("querying primary key: %w", err)
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("reading primary key: %w", err)
+	}
+	if len(columns) == 0 {
+		return nil, ErrNoPrimaryKey
+	}
+
+	return columns, nil
+}
+
+// RowByPrimaryKey returns the row of a table whose primary key columns have
+// the text representations in key, in key order. It returns ErrRowNotFound if
+// there is no such row.
+func RowByPrimaryKey(ctx context.Context, db *pgxpool.Pool, table TableName, key []string) (*Result, error) {
+	columns, err := PrimaryKey(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != len(columns) {
+		return nil, fmt.Errorf("%w: primary key has %d columns, got %d values", ErrInvalidKey, len(columns), len(key))
+	}
+
+	// String arguments are sent in the text format, so Postgres parses them
+	// as values of the column types.
+	conditions := make([]string, len(columns))
+	args := make([]any, len(key))
+	for i, column := range columns {
+		conditions[i] = fmt.Sprintf("%s = $%d", pgx.Identifier{column}.Sanitize(), i+1)
+		args[i] = key[i]
+	}
+
+	sql := "SELECT * FROM " + pgx.Identifier{table.Schema, table.Name}.Sanitize() +
+		" WHERE " + strings.Join(conditions, " AND ")
+	result, err := QueryReadOnly(ctx, db, sql, 1, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Rows) == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	return result, nil
+}
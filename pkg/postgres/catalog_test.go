@@ -0,0 +1,39 @@
+package postgres
+
+import "testing"
+
+func TestTableString(t *testing.T) {
+	defaultID := "nextval('actor_actor_id_seq'::regclass)"
+	table := Table{
+		TableName: TableName{Schema: "public", Name: "actor"},
+		Kind:      "table",
+		Columns: []TableColumn{
+			{Name: "actor_id", Type: "integer", Default: &defaultID},
+			{Name: "first_name", Type: "character varying(45)"},
+			{Name: "nickname", Type: "text", Nullable: true},
+		},
+		Constraints: []Constraint{
+			{Name: "actor_pkey", Type: "primary key", Definition: "PRIMARY KEY (actor_id)"},
+		},
+		Indexes: []Index{
+			{Name: "actor_pkey", Definition: "CREATE UNIQUE INDEX actor_pkey ON public.actor USING btree (actor_id)"},
+		},
+	}
+
+	want := `Table "public"."actor"
+
+Columns:
+  actor_id integer NOT NULL DEFAULT nextval('actor_actor_id_seq'::regclass)
+  first_name character varying(45) NOT NULL
+  nickname text
+
+Constraints:
+  actor_pkey PRIMARY KEY (actor_id)
+
+Indexes:
+  CREATE UNIQUE INDEX actor_pkey ON public.actor USING btree (actor_id)
+`
+	if got := table.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
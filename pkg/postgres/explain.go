@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Plan is the execution plan of a query.
+type Plan struct {
+	// Text is the plan in the text format of EXPLAIN.
+	Text string
+	// Tables holds the tables the plan reads, in order of appearance.
+	Tables []TableName
+}
+
+// Explain returns the plan Postgres chooses for sql without running it. Like
+// QueryReadOnly, it runs inside a READ ONLY transaction that is rolled back.
+//
+// The EXPLAIN options are always given as a parenthesized list, after which
+// Postgres accepts neither ANALYZE nor another option list, so sql can't make
+// EXPLAIN run the statement.
+func Explain(ctx context.Context, db *pgxpool.Pool, sql string) (*Plan, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	rows, err := tx.Query(ctx, "EXPLAIN (FORMAT TEXT) "+sql)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = tx.QueryRow(ctx, "EXPLAIN (VERBOSE, FORMAT JSON) "+sql).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := planTables(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan: %w", err)
+	}
+
+	return &Plan{
+		Text:   strings.Join(lines, "\n"),
+		Tables: tables,
+	}, nil
+}
+
+// planNode is a node of a plan in the JSON format of EXPLAIN VERBOSE.
+type planNode struct {
+	Schema       string     `json:"Schema"`
+	RelationName string     `json:"Relation Name"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// planTables returns the tables read by a plan in the JSON format of EXPLAIN
+// VERBOSE.
+func planTables(data []byte) ([]TableName, error) {
+	var plans []struct {
+		Plan planNode `json:"Plan"`
+	}
+	err := json.Unmarshal(data, &plans)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []TableName
+	var walk func(node planNode)
+	walk = func(node planNode) {
+		if node.RelationName != "" {
+			table := TableName{Schema: node.Schema, Name: node.RelationName}
+			if !slices.Contains(tables, table) {
+				tables = append(tables, table)
+			}
+		}
+		for _, child := range node.Plans {
+			walk(child)
+		}
+	}
+	for _, plan := range plans {
+		walk(plan.Plan)
+	}
+
+	return tables, nil
+}
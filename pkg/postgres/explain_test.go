@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlanTables(t *testing.T) {
+	plan := `[{"Plan": {
+		"Node Type": "Hash Join",
+		"Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "rental", "Schema": "public", "Alias": "r"},
+			{"Node Type": "Hash", "Plans": [
+				{"Node Type": "Index Scan", "Relation Name": "customer", "Schema": "public", "Alias": "c"},
+				{"Node Type": "Seq Scan", "Relation Name": "rental", "Schema": "public", "Alias": "r2"}
+			]}
+		]
+	}}]`
+
+	got, err := planTables([]byte(plan))
+	if err != nil {
+		t.Fatalf("planTables: %v", err)
+	}
+	want := []TableName{{Schema: "public", Name: "rental"}, {Schema: "public", Name: "customer"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("planTables = %v, want %v", got, want)
+	}
+}
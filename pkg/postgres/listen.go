@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ChangeChannel is the channel Listen receives table changes on. Applications
+// can NOTIFY it themselves with the JSON encoding of a Change as payload.
+const ChangeChannel = "pgmcp_changes"
+
+// DDLChannel is the channel Listen receives schema changes on. The payload is
+// the command tag, e.g. ALTER TABLE.
+const DDLChannel = "pgmcp_ddl"
+
+// Change is the payload of a notification on ChangeChannel.
+type Change struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// Op is the operation that changed the table: INSERT, UPDATE, DELETE or
+	// TRUNCATE.
+	Op string `json:"op,omitempty"`
+	// Key holds the text representations of the primary key columns of the
+	// changed row, in key order. It is empty if the row is unknown or the
+	// whole table changed.
+	Key []string `json:"key,omitempty"`
+}
+
+func (c Change) TableName() TableName {
+	return TableName{Schema: c.Schema, Name: c.Table}
+}
+
+// Listen opens a connection dedicated to listening on ChangeChannel and
+// DDLChannel. It calls onChange with each table change and onDDL with each
+// schema change until ctx is done or the connection fails. Payloads on
+// ChangeChannel that are not a Change are ignored. It always returns a non-nil
+// error.
+func Listen(ctx context.Context, db *pgxpool.Pool, onChange func(Change), onDDL func(tag string)) error {
+	config := db.Config().ConnConfig.Copy()
+	// Waiting for a notification is interrupted at once when ctx is done;
+	// there is no statement to cancel on the server.
+	config.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.DeadlineContextWatcherHandler{Conn: conn.Conn()}
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return fmt.Errorf("connecting: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	for _, channel := range []string{ChangeChannel, DDLChannel} {
+		_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+		if err != nil {
+			return fmt.Errorf("listening on %s: %w", channel, err)
+		}
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for notification: %w", err)
+		}
+
+		if notification.Channel == DDLChannel {
+			onDDL(notification.Payload)
+			continue
+		}
+
+		var change Change
+		err = json.Unmarshal([]byte(notification.Payload), &change)
+		if err != nil || change.Schema == "" || change.Table == "" {
+			continue
+		}
+		onChange(change)
+	}
+}
+
+// changeTriggerFunction is the trigger function that notifies ChangeChannel of
+// changed rows. The trigger arguments are the names of the primary key
+// columns.
+const changeTriggerFunction = `
+CREATE OR REPLACE FUNCTION pgmcp.notify_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+	changed jsonb;
+	key_values text[] := '{}';
+BEGIN
+	IF TG_LEVEL = 'ROW' THEN
+		IF TG_OP = 'DELETE' THEN
+			changed := to_jsonb(OLD);
+		ELSE
+			changed := to_jsonb(NEW);
+		END IF;
+		FOR i IN 0 .. TG_NARGS - 1 LOOP
+			key_values := key_values || (changed ->> TG_ARGV[i]);
+		END LOOP;
+	END IF;
+
+	PERFORM pg_notify('` + ChangeChannel + `', json_build_object(
+		'schema', TG_TABLE_SCHEMA,
+		'table', TG_TABLE_NAME,
+		'op', TG_OP,
+		'key', key_values
+	)::text);
+	RETURN NULL;
+END
+$$`
+
+// InstallChangeTrigger creates triggers on a table that notify ChangeChannel
+// of every change. The trigger function is created in the schema pgmcp;
+// dropping the schema with CASCADE removes the triggers again. Rows are
+// identified by their primary key, if the table has one.
+func InstallChangeTrigger(ctx context.Context, db *pgxpool.Pool, table TableName) error {
+	key, err := PrimaryKey(ctx, db, table)
+	if err != nil && !errors.Is(err, ErrNoPrimaryKey) {
+		return err
+	}
+
+	// Trigger arguments are string literals.
+	args := make([]string, len(key))
+	for i, column := range key {
+		args[i] = "'" + strings.ReplaceAll(column, "'", "''") + "'"
+	}
+
+	identifier := pgx.Identifier{table.Schema, table.Name}.Sanitize()
+	statements := []string{
+		`CREATE SCHEMA IF NOT EXISTS pgmcp`,
+		changeTriggerFunction,
+		`DROP TRIGGER IF EXISTS pgmcp_notify_change ON ` + identifier,
+		`DROP TRIGGER IF EXISTS pgmcp_notify_truncate ON ` + identifier,
+		fmt.Sprintf(`CREATE TRIGGER pgmcp_notify_change
+			AFTER INSERT OR UPDATE OR DELETE ON %s
+			FOR EACH ROW EXECUTE FUNCTION pgmcp.notify_change(%s)`, identifier, strings.Join(args, ", ")),
+		`CREATE TRIGGER pgmcp_notify_truncate
+			AFTER TRUNCATE ON ` + identifier + `
+			FOR EACH STATEMENT EXECUTE FUNCTION pgmcp.notify_change()`,
+	}
+
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		for _, statement := range statements {
+			_, err := tx.Exec(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("installing change trigger: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ddlTriggerFunction is the event trigger function that notifies DDLChannel of
+// schema changes.
+const ddlTriggerFunction = `
+CREATE OR REPLACE FUNCTION pgmcp.notify_ddl() RETURNS event_trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	PERFORM pg_notify('` + DDLChannel + `', TG_TAG);
+END
+$$`
+
+// InstallDDLTrigger creates an event trigger that notifies DDLChannel at the
+// end of every DDL command. Creating event triggers requires superuser
+// privileges. Like the change triggers, it is removed by dropping the schema
+// pgmcp with CASCADE.
+func InstallDDLTrigger(ctx context.Context, db *pgxpool.Pool) error {
+	statements := []string{
+		`CREATE SCHEMA IF NOT EXISTS pgmcp`,
+		ddlTriggerFunction,
+		`DROP EVENT TRIGGER IF EXISTS pgmcp_notify_ddl`,
+		`CREATE EVENT TRIGGER pgmcp_notify_ddl ON ddl_command_end
+			EXECUTE FUNCTION pgmcp.notify_ddl()`,
+	}
+
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		for _, statement := range statements {
+			_, err := tx.Exec(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("installing DDL trigger: %w", err)
+			}
+		}
+		return nil
+	})
+}
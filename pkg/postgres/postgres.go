@@ -0,0 +1,178 @@
+// Package postgres runs the queries pgmcp executes on behalf of MCP clients.
+package postgres
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// cancelDeadlineDelay is how long a connection waits for a cancelled query to
+// stop on the server before the connection is closed.
+const cancelDeadlineDelay = 5 * time.Second
+
+// Connect creates a connection pool for the given DSN and verifies that the
+// database is reachable. Cancelling the context of a query sends a cancel
+// request to the server, so the statement stops running on the backend too.
+func Connect(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parsing dsn: %w", err)
+	}
+
+	config.ConnConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{
+			Conn:          conn,
+			DeadlineDelay: cancelDeadlineDelay,
+		}
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("creating pool: %w", err)
+	}
+
+	err = pool.Ping(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("pinging database: %w", err)
+	}
+
+	return pool, nil
+}
+
+// IsSuperuser reports whether the role the pool connects as is a superuser.
+// READ ONLY transactions don't stop a superuser from reading server files or
+// calling functions with side effects.
+func IsSuperuser(ctx context.Context, db *pgxpool.Pool) (bool, error) {
+	var superuser bool
+	err := db.QueryRow(ctx, `SELECT rolsuper FROM pg_catalog.pg_roles WHERE rolname = current_user`).Scan(&superuser)
+	if err != nil {
+		return false, fmt.Errorf("checking role: %w", err)
+	}
+	return superuser, nil
+}
+
+// Column describes a column of a query result.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Result is the result of a query. Rows holds JSON friendly values in the same
+// order as Columns.
+type Result struct {
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+	// Truncated is set when the query returned more than the requested maximum
+	// number of rows.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ProgressInterval is the number of rows QueryReadOnly fetches between calls to
+// its progress function.
+const ProgressInterval = 100
+
+// QueryReadOnly executes sql with the given arguments inside a READ ONLY
+// transaction and returns at most maxRows rows. The transaction is always
+// rolled back. A maxRows of 0 means no limit. If progress is not nil, it is
+// called with the number of rows fetched so far every ProgressInterval rows.
+//
+// Once more than maxRows rows arrive, the statement is cancelled so that the
+// server stops producing rows nobody reads. With a pool made by Connect the
+// connection survives the cancellation; errors after the last returned row
+// are ignored.
+func QueryReadOnly(ctx context.Context, db *pgxpool.Pool, sql string, maxRows int, progress func(rows int), args ...any) (*Result, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	queryCtx, cancelQuery := context.WithCancel(ctx)
+	defer cancelQuery()
+
+	rows, err := tx.Query(queryCtx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	typeMap := tx.Conn().TypeMap()
+	fields := rows.FieldDescriptions()
+	result := Result{
+		Columns: make([]Column, len(fields)),
+		Rows:    [][]any{},
+	}
+	for i, field := range fields {
+		typeName := fmt.Sprintf("oid:%d", field.DataTypeOID)
+		if t, ok := typeMap.TypeForOID(field.DataTypeOID); ok {
+			typeName = t.Name
+		}
+		result.Columns[i] = Column{Name: field.Name, Type: typeName}
+	}
+
+	for rows.Next() {
+		if maxRows > 0 && len(result.Rows) == maxRows {
+			result.Truncated = true
+			// Closing the rows would otherwise read the rest of the result.
+			cancelQuery()
+			break
+		}
+
+		values, err := rows.Values()
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+		for i, v := range values {
+			values[i] = jsonValue(v)
+		}
+		result.Rows = append(result.Rows, values)
+
+		if progress != nil && len(result.Rows)%ProgressInterval == 0 {
+			progress(len(result.Rows))
+		}
+	}
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil && !result.Truncated {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// jsonValue converts values decoded by pgx into values that marshal to
+// sensible JSON.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case [16]byte:
+		// uuid
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	case []byte:
+		// bytea, in the Postgres hex output format
+		return `\x` + hex.EncodeToString(v)
+	case fmt.Stringer:
+		if _, ok := v.(json.Marshaler); ok {
+			break
+		}
+		return v.String()
+	}
+
+	_, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return v
+}
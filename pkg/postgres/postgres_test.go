@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestJSONValue(t *testing.T) {
+	date := time.Date(2025, 6, 18, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		v    any
+		want any
+	}{
+		{"nil", nil, nil},
+		{"int", int32(42), int32(42)},
+		{"uuid", [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0},
+			"12345678-9abc-def0-1234-56789abcdef0"},
+		{"bytea", []byte{0xde, 0xad, 0xbe, 0xef}, `\xdeadbeef`},
+		{"empty bytea", []byte{}, `\x`},
+		{"stringer", 90 * time.Second, "1m30s"},
+		{"stringer and marshaler", date, date},
+		{"NaN", math.NaN(), "NaN"},
+		{"Inf", math.Inf(1), "+Inf"},
+		{"-Inf", math.Inf(-1), "-Inf"},
+	}
+
+	for _, tt := range tests {
+		if got := jsonValue(tt.v); got != tt.want {
+			t.Errorf("jsonValue(%s) = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
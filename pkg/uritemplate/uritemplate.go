@@ -0,0 +1,422 @@
+// Package uritemplate expands and matches RFC 6570 URI templates.
+//
+// Variables hold single string values, except that an exploded variable of a
+// simple expression, {var*}, holds a list: its value is the list as expanded,
+// i.e. the percent-encoded items separated by commas. JoinList and SplitList
+// convert between the two. Associative array values are not supported.
+// Templates are matched by turning them into regular expressions, so matching
+// is only as precise as the template: e.g. adjacent variables without a
+// literal between them can't be told apart.
+//
+// See: https://www.rfc-editor.org/rfc/rfc6570
+package uritemplate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// operator describes how an expression is expanded. See RFC 6570, Appendix A.
+type operator struct {
+	first         string
+	sep           string
+	named         bool
+	ifEmpty       string
+	allowReserved bool
+}
+
+var operators = map[byte]operator{
+	0:   {first: "", sep: ","},
+	'+': {first: "", sep: ",", allowReserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+	'#': {first: "#", sep: ",", allowReserved: true},
+}
+
+// varSpec is a variable of an expression.
+type varSpec struct {
+	name string
+	// prefix is the maximum number of characters of the value to expand, or
+	// zero for the whole value.
+	prefix int
+	// explode makes the variable of a simple expression a list. It has no
+	// effect on other expressions.
+	explode bool
+}
+
+// isList reports whether the variable holds a list in an expression with the
+// operator op.
+func (v varSpec) isList(op byte) bool {
+	return v.explode && op == 0
+}
+
+// part is a literal or, if vars is not empty, an expression.
+type part struct {
+	literal string
+	op      byte
+	vars    []varSpec
+}
+
+func (p part) isQuery() bool {
+	return p.op == '?' || p.op == '&'
+}
+
+// Template is a parsed URI template.
+type Template struct {
+	raw   string
+	parts []part
+
+	// pattern matches the part of a URI before the query expressions.
+	pattern *regexp.Regexp
+	// groups holds the variable names of the capture groups of pattern.
+	groups []string
+	// query holds the names of the variables of the query expressions.
+	query []string
+	// lists holds the names of the list variables.
+	lists map[string]bool
+}
+
+var varNameRe = regexp.MustCompile(`^(?:[A-Za-z0-9_.]|%[0-9A-Fa-f]{2})+$`)
+
+// Parse parses a URI template. Query expressions ({?var} and {&var}) may only
+// appear at the end of the template.
+func Parse(template string) (*Template, error) {
+	t := &Template{raw: template}
+
+	rest := template
+	for rest != "" {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			start = len(rest)
+		}
+		if end := strings.IndexByte(rest[:start], '}'); end >= 0 {
+			return nil, fmt.Errorf("unexpected '}' at offset %d", len(template)-len(rest)+end)
+		}
+		if start > 0 {
+			t.parts = append(t.parts, part{literal: rest[:start]})
+			rest = rest[start:]
+			continue
+		}
+
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return nil, errors.New("unterminated expression")
+		}
+		expr, err := parseExpression(rest[1:end])
+		if err != nil {
+			return nil, fmt.Errorf("expression %s: %w", rest[:end+1], err)
+		}
+		t.parts = append(t.parts, expr)
+		rest = rest[end+1:]
+	}
+
+	err := t.compile()
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// MustParse is like Parse but panics if the template is invalid.
+func MustParse(template string) *Template {
+	t, err := Parse(template)
+	if err != nil {
+		panic(fmt.Sprintf("uritemplate: Parse(%q): %v", template, err))
+	}
+	return t
+}
+
+func parseExpression(expr string) (part, error) {
+	p := part{}
+	if expr != "" {
+		if _, ok := operators[expr[0]]; ok && expr[0] != 0 {
+			p.op = expr[0]
+			expr = expr[1:]
+		} else if strings.ContainsRune("=,!@|", rune(expr[0])) {
+			return part{}, fmt.Errorf("reserved operator %q", expr[0])
+		}
+	}
+
+	for _, spec := range strings.Split(expr, ",") {
+		v := varSpec{}
+		if name, ok := strings.CutSuffix(spec, "*"); ok {
+			v.explode = true
+			spec = name
+		}
+		if name, prefix, ok := strings.Cut(spec, ":"); ok {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n <= 0 || n >= 10000 {
+				return part{}, fmt.Errorf("invalid prefix %q", prefix)
+			}
+			v.prefix = n
+			spec = name
+		}
+		if !varNameRe.MatchString(spec) {
+			return part{}, fmt.Errorf("invalid variable name %q", spec)
+		}
+		v.name = spec
+		p.vars = append(p.vars, v)
+	}
+
+	return p, nil
+}
+
+// compile builds the regular expression matching the template.
+func (t *Template) compile() error {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, p := range t.parts {
+		if len(t.query) > 0 && !p.isQuery() {
+			return errors.New("query expressions must be at the end of the template")
+		}
+
+		switch {
+		case p.vars == nil:
+			b.WriteString(regexp.QuoteMeta(p.literal))
+		case p.isQuery():
+			for _, v := range p.vars {
+				t.query = append(t.query, v.name)
+			}
+		default:
+			op := operators[p.op]
+			value := `((?:[A-Za-z0-9\-._~]|%[0-9A-Fa-f]{2})*)`
+			if p.op == '.' {
+				// Label values can't be told apart from the separator.
+				value = `((?:[A-Za-z0-9\-_~]|%[0-9A-Fa-f]{2})*)`
+			}
+			if op.allowReserved {
+				value = `([^,?#]*)`
+				if p.op == '#' {
+					value = `([^,]*)`
+				}
+			}
+
+			b.WriteString("(?:")
+			for j, v := range p.vars {
+				if j == 0 {
+					b.WriteString(regexp.QuoteMeta(op.first))
+				} else {
+					b.WriteString("(?:" + regexp.QuoteMeta(op.sep))
+				}
+				switch {
+				case op.named:
+					// ;name without a value is the empty value.
+					b.WriteString(regexp.QuoteMeta(v.name) + "=?" + value)
+				case v.isList(p.op):
+					b.WriteString(`((?:[A-Za-z0-9\-._~,]|%[0-9A-Fa-f]{2})*)`)
+				default:
+					b.WriteString(value)
+				}
+				if j > 0 {
+					b.WriteString(")?")
+				}
+				t.groups = append(t.groups, v.name)
+				if v.isList(p.op) {
+					if t.lists == nil {
+						t.lists = make(map[string]bool)
+					}
+					t.lists[v.name] = true
+				}
+			}
+			b.WriteString(")?")
+		}
+	}
+	if len(t.query) > 0 {
+		b.WriteString(`(?:\?([^#]*))?`)
+	}
+	b.WriteString("$")
+
+	pattern, err := regexp.Compile(b.String())
+	if err != nil {
+		return fmt.Errorf("compiling pattern: %w", err)
+	}
+	t.pattern = pattern
+	return nil
+}
+
+// String returns the template as it was parsed.
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Names returns the names of the variables of the template, in order.
+func (t *Template) Names() []string {
+	names := append([]string(nil), t.groups...)
+	return append(names, t.query...)
+}
+
+// Expand expands the template with the given values. Variables without a
+// value are undefined and expand to nothing. The values of list variables are
+// written as they are; see JoinList.
+func (t *Template) Expand(values map[string]string) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.vars == nil {
+			b.WriteString(p.literal)
+			continue
+		}
+
+		op := operators[p.op]
+		first := true
+		for _, v := range p.vars {
+			value, ok := values[v.name]
+			if !ok {
+				continue
+			}
+
+			if first {
+				b.WriteString(op.first)
+				first = false
+			} else {
+				b.WriteString(op.sep)
+			}
+
+			if op.named {
+				b.WriteString(v.name)
+				if value == "" {
+					b.WriteString(op.ifEmpty)
+					continue
+				}
+				b.WriteString("=")
+			}
+
+			if v.isList(p.op) {
+				b.WriteString(value)
+				continue
+			}
+			if v.prefix > 0 && utf8.RuneCountInString(value) > v.prefix {
+				value = string([]rune(value)[:v.prefix])
+			}
+			b.WriteString(encode(value, op.allowReserved))
+		}
+	}
+	return b.String()
+}
+
+// Match reports whether uri is an expansion of the template and returns the
+// values of the variables it defines, percent-decoded. The values of list
+// variables are returned as expanded, for SplitList to split.
+func (t *Template) Match(uri string) (map[string]string, bool) {
+	m := t.pattern.FindStringSubmatchIndex(uri)
+	if m == nil {
+		return nil, false
+	}
+
+	values := make(map[string]string)
+	for i, name := range t.groups {
+		start, end := m[2*(i+1)], m[2*(i+1)+1]
+		if start < 0 {
+			continue
+		}
+		if t.lists[name] {
+			values[name] = uri[start:end]
+			continue
+		}
+		value, err := url.PathUnescape(uri[start:end])
+		if err != nil {
+			return nil, false
+		}
+		values[name] = value
+	}
+
+	if len(t.query) > 0 {
+		i := len(t.groups) + 1
+		start, end := m[2*i], m[2*i+1]
+		if start >= 0 {
+			ok := t.matchQuery(uri[start:end], values)
+			if !ok {
+				return nil, false
+			}
+		}
+	}
+
+	return values, true
+}
+
+// matchQuery adds the values of the query string query to values. It reports
+// false if query has parameters that are not variables of the template.
+func (t *Template) matchQuery(query string, values map[string]string) bool {
+	if query == "" {
+		return true
+	}
+
+	for _, param := range strings.Split(query, "&") {
+		name, value, _ := strings.Cut(param, "=")
+		if !slices.Contains(t.query, name) {
+			return false
+		}
+		value, err := url.PathUnescape(value)
+		if err != nil {
+			return false
+		}
+		values[name] = value
+	}
+	return true
+}
+
+// JoinList returns the value of a list variable holding items.
+func JoinList(items []string) string {
+	encoded := make([]string, len(items))
+	for i, item := range items {
+		encoded[i] = encode(item, false)
+	}
+	return strings.Join(encoded, ",")
+}
+
+// SplitList returns the items of the value of a list variable. Commas separate
+// items; percent-encoded commas are part of an item.
+func SplitList(value string) ([]string, error) {
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		decoded, err := url.PathUnescape(item)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = decoded
+	}
+	return items, nil
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+// encode percent-encodes the characters of s that are not unreserved, or, if
+// allowReserved is set, that are neither unreserved nor reserved. Existing
+// percent-encoded triplets are kept if allowReserved is set.
+func encode(s string, allowReserved bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			b.WriteByte(c)
+		case allowReserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			b.WriteByte(c)
+		case allowReserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteString(s[i : i+3])
+			i += 2
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xF])
+		}
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'A' <= c && c <= 'F' || 'a' <= c && c <= 'f'
+}
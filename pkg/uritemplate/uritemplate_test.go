@@ -0,0 +1,184 @@
+package uritemplate
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+// values are the string variables of the examples in RFC 6570, Section 3.2.
+var values = map[string]string{
+	"var":   "value",
+	"hello": "Hello World!",
+	"path":  "/foo/bar",
+	"empty": "",
+	"x":     "1024",
+	"y":     "768",
+	"base":  "http://example.com/home/",
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{var}", "value"},
+		{"{hello}", "Hello%20World%21"},
+		{"{+hello}", "Hello%20World!"},
+		{"{+path}/here", "/foo/bar/here"},
+		{"{base}index", "http%3A%2F%2Fexample.com%2Fhome%2Findex"},
+		{"{+base}index", "http://example.com/home/index"},
+		{"{#var}", "#value"},
+		{"{#hello}", "#Hello%20World!"},
+		{"map?{x,y}", "map?1024,768"},
+		{"{x,hello,y}", "1024,Hello%20World%21,768"},
+		{"{var:3}", "val"},
+		{"{var:30}", "value"},
+		{"X{.var}", "X.value"},
+		{"X{.x,y}", "X.1024.768"},
+		{"{/var}", "/value"},
+		{"{/var,x}/here", "/value/1024/here"},
+		{"{;x,y}", ";x=1024;y=768"},
+		{"{;x,y,empty}", ";x=1024;y=768;empty"},
+		{"{?x,y}", "?x=1024&y=768"},
+		{"{?x,y,empty}", "?x=1024&y=768&empty="},
+		{"?fixed=yes{&x}", "?fixed=yes&x=1024"},
+		{"{var}{?undef}", "value"},
+		{"{undef}", ""},
+		{"{/undef,var}", "/value"},
+	}
+
+	for _, tt := range tests {
+		template, err := Parse(tt.template)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.template, err)
+			continue
+		}
+		if got := template.Expand(values); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"{var",
+		"var}",
+		"{}",
+		"{var:0}",
+		"{var:x}",
+		"{=var}",
+		"{va r}",
+		"{?x}/path",
+	}
+
+	for _, template := range tests {
+		if _, err := Parse(template); err == nil {
+			t.Errorf("Parse(%q): expected error, got nil", template)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		template string
+		uri      string
+		want     map[string]string
+	}{
+		{
+			"postgres://{db}/{schema}/{table}/sample{?limit}",
+			"postgres://dvdrental/public/actor/sample",
+			map[string]string{"db": "dvdrental", "schema": "public", "table": "actor"},
+		},
+		{
+			"postgres://{db}/{schema}/{table}/sample{?limit}",
+			"postgres://dvdrental/public/actor/sample?limit=5",
+			map[string]string{"db": "dvdrental", "schema": "public", "table": "actor", "limit": "5"},
+		},
+		{
+			"postgres://{db}/{schema}/{table}/row/{pk}",
+			"postgres://my%20db/public/film_actor/row/1%2C23",
+			map[string]string{"db": "my db", "schema": "public", "table": "film_actor", "pk": "1,23"},
+		},
+		{"{+path}/here", "/foo/bar/here", map[string]string{"path": "/foo/bar"}},
+		{"X{.x,y}", "X.1024.768", map[string]string{"x": "1024", "y": "768"}},
+		{"{;x,empty}", ";x=1024;empty", map[string]string{"x": "1024", "empty": ""}},
+		{"{?x,y}", "?y=768&x=1024", map[string]string{"x": "1024", "y": "768"}},
+		{"{#hello}", "#Hello%20World!", map[string]string{"hello": "Hello World!"}},
+		{
+			"postgres://{db}/{schema}/{table}/row/{pk*}",
+			"postgres://db/public/film_actor/row/1,23",
+			map[string]string{"db": "db", "schema": "public", "table": "film_actor", "pk": "1,23"},
+		},
+		{"row/{pk*}", "row/a%2Cb,c", map[string]string{"pk": "a%2Cb,c"}},
+	}
+
+	for _, tt := range tests {
+		template := MustParse(tt.template)
+		got, ok := template.Match(tt.uri)
+		if !ok {
+			t.Errorf("Match(%q, %q) = false, want true", tt.template, tt.uri)
+			continue
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.template, tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestMatchExpansion(t *testing.T) {
+	template := MustParse("postgres://{db}/{schema}/{table}/row/{pk}")
+	want := map[string]string{"db": "db", "schema": "a/b", "table": "c?d", "pk": "x,y z"}
+
+	got, ok := template.Match(template.Expand(want))
+	if !ok || !maps.Equal(got, want) {
+		t.Errorf("Match(Expand(%v)) = %v, %v, want the values", want, got, ok)
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		items []string
+		value string
+	}{
+		{[]string{"1"}, "1"},
+		{[]string{"1", "23"}, "1,23"},
+		{[]string{"a,b", "c d"}, "a%2Cb,c%20d"},
+	}
+
+	template := MustParse("row/{pk*}")
+	for _, tt := range tests {
+		if got := JoinList(tt.items); got != tt.value {
+			t.Errorf("JoinList(%q) = %q, want %q", tt.items, got, tt.value)
+		}
+
+		values, ok := template.Match(template.Expand(map[string]string{"pk": tt.value}))
+		if !ok {
+			t.Errorf("Match(Expand(%q)) = false, want true", tt.value)
+			continue
+		}
+		got, err := SplitList(values["pk"])
+		if err != nil || !slices.Equal(got, tt.items) {
+			t.Errorf("SplitList(%q) = %q, %v, want %q", values["pk"], got, err, tt.items)
+		}
+	}
+}
+
+func TestNoMatch(t *testing.T) {
+	tests := []struct {
+		template string
+		uri      string
+	}{
+		{"postgres://{db}/{schema}/{table}/sample{?limit}", "postgres://db/public/actor/row/1"},
+		{"postgres://{db}/{schema}/{table}/sample{?limit}", "postgres://db/public/actor/sample?limit=5&order=x"},
+		{"postgres://{db}/{schema}/{table}/row/{pk}", "postgres://db/public/actor/row/1?x=1"},
+		{"postgres://{db}/{schema}/{table}/row/{pk}", "postgres://db/public/actor/extra/row/1"},
+		{"{var}", "a/b"},
+	}
+
+	for _, tt := range tests {
+		if got, ok := MustParse(tt.template).Match(tt.uri); ok {
+			t.Errorf("Match(%q, %q) = %v, true, want false", tt.template, tt.uri, got)
+		}
+	}
+}
@@ -2,3 +2,6 @@
 set -e
 
 pg_restore -U postgres -d dvdrental /seed/dvdrental.tar
+
+# pgmcp connects as a role that can only read.
+psql -U postgres -d dvdrental -c "CREATE ROLE pgmcp LOGIN PASSWORD 'pgmcp' IN ROLE pg_read_all_data"